@@ -2,16 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"trees/graph"
 	"trees/store"
+	"trees/watch"
 )
 
 type Handler struct {
 	store   *store.Store
 	checker graph.GitChecker
 	mux     *http.ServeMux
+	watcher *watch.Watcher
 }
 
 func NewHandler(storePath string, checker graph.GitChecker) (*Handler, error) {
@@ -19,9 +24,23 @@ func NewHandler(storePath string, checker graph.GitChecker) (*Handler, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewHandlerForStore(s, checker), nil
+}
+
+// NewHandlerForStore returns a handler serving an already opened store, such
+// as one kept as a tree of files with store.NewTree.
+func NewHandlerForStore(s *store.Store, checker graph.GitChecker) *Handler {
 	h := &Handler{store: s, checker: checker}
 	h.setupRoutes()
-	return h, nil
+	return h
+}
+
+// SetCheckouts maps repository identities to local checkout directories, so
+// evidence recorded on another machine resolves to files on this one.
+func (h *Handler) SetCheckouts(checkouts map[string]string) {
+	h.store.WithGraph(func(g *graph.Graph) {
+		g.ResolveRepoPaths(checkouts)
+	})
 }
 
 func (h *Handler) Mux() *http.ServeMux {
@@ -34,10 +53,21 @@ func (h *Handler) setupRoutes() {
 	h.mux.HandleFunc("POST /claims", h.createClaim)
 	h.mux.HandleFunc("GET /claims", h.listClaims)
 	h.mux.HandleFunc("GET /claims/{id}", h.getClaim)
+	h.mux.HandleFunc("GET /claims/{id}/blame", h.blameClaim)
 	h.mux.HandleFunc("POST /claims/{id}/evidence", h.linkEvidence)
+	h.mux.HandleFunc("POST /claims/{id}/review", h.reviewClaim)
 	h.mux.HandleFunc("POST /evidence", h.createEvidence)
 	h.mux.HandleFunc("GET /evidence", h.listEvidence)
 	h.mux.HandleFunc("GET /evidence/{id}", h.getEvidence)
+	h.mux.HandleFunc("DELETE /evidence/{id}", h.deleteEvidence)
+	h.mux.HandleFunc("POST /evidence/{id}/reanchor", h.reanchorEvidence)
+	h.mux.HandleFunc("GET /evidence/{id}/timeline", h.evidenceTimeline)
+	h.mux.HandleFunc("GET /validate", h.validate)
+	h.mux.HandleFunc("POST /impact", h.impact)
+	h.mux.HandleFunc("POST /repos", h.createRepo)
+	h.mux.HandleFunc("GET /repos", h.listRepos)
+	h.mux.HandleFunc("GET /graph", h.getGraph)
+	h.mux.HandleFunc("POST /graph/merge", h.mergeGraph)
 }
 
 func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
@@ -45,9 +75,41 @@ func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// provenanceFrom builds the provenance of a request from the X-Trees-*
+// headers, with any fields set in the request body taking precedence.
+// Returns nil when nothing is known about the origin.
+func provenanceFrom(r *http.Request, body *graph.Provenance) *graph.Provenance {
+	p := &graph.Provenance{
+		Author:  r.Header.Get("X-Trees-Author"),
+		Session: r.Header.Get("X-Trees-Session"),
+		Tool:    r.Header.Get("X-Trees-Tool"),
+		Source:  r.Header.Get("X-Trees-Source"),
+	}
+	if body != nil {
+		if body.Author != "" {
+			p.Author = body.Author
+		}
+		if body.Session != "" {
+			p.Session = body.Session
+		}
+		if body.Tool != "" {
+			p.Tool = body.Tool
+		}
+		if body.Source != "" {
+			p.Source = body.Source
+		}
+	}
+	if p.IsZero() {
+		return nil
+	}
+	return p
+}
+
 func (h *Handler) createClaim(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Content string `json:"content"`
+		Content    string            `json:"content"`
+		Tags       []string          `json:"tags"`
+		Provenance *graph.Provenance `json:"provenance"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
@@ -60,7 +122,8 @@ func (h *Handler) createClaim(w http.ResponseWriter, r *http.Request) {
 
 	var claim *graph.ClaimNode
 	h.store.WithGraph(func(g *graph.Graph) {
-		claim = g.AddClaim(req.Content)
+		claim = g.AddClaim(req.Content, req.Tags...)
+		claim.Provenance = provenanceFrom(r, req.Provenance)
 	})
 	h.store.Save()
 
@@ -70,10 +133,33 @@ func (h *Handler) createClaim(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) listClaims(w http.ResponseWriter, r *http.Request) {
-	g := h.store.Graph()
-	claims := make([]*graph.ClaimNode, 0, len(g.Claims))
-	for _, c := range g.Claims {
-		claims = append(claims, c)
+	g := h.store.Snapshot()
+	claims := g.ClaimsWithTags(r.URL.Query()["tag"]...)
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []*graph.ClaimNode{}
+		for _, c := range claims {
+			if c.Status == status {
+				filtered = append(filtered, c)
+			}
+		}
+		claims = filtered
+	}
+
+	// Narrow to claims from one author or session, e.g. to trace everything
+	// an agent asserted once one of its claims turns out wrong.
+	author := r.URL.Query().Get("author")
+	session := r.URL.Query().Get("session")
+	if author != "" || session != "" {
+		filtered := []*graph.ClaimNode{}
+		for _, c := range claims {
+			p := c.Provenance
+			if p == nil || (author != "" && p.Author != author) || (session != "" && p.Session != session) {
+				continue
+			}
+			filtered = append(filtered, c)
+		}
+		claims = filtered
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -82,12 +168,94 @@ func (h *Handler) listClaims(w http.ResponseWriter, r *http.Request) {
 
 type evidenceWithValidity struct {
 	*graph.EvidenceNode
-	Valid bool `json:"valid"`
+	graph.Validity
+	Kind           string            `json:"kind,omitempty"`
+	LinkProvenance *graph.Provenance `json:"link_provenance,omitempty"`
+	Snippet        *graph.Snippet    `json:"snippet,omitempty"`
+}
+
+// includes reports whether the include query parameter, a comma-separated
+// list, names the given extra.
+func includes(r *http.Request, extra string) bool {
+	for _, v := range r.URL.Query()["include"] {
+		for _, name := range strings.Split(v, ",") {
+			if strings.TrimSpace(name) == extra {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkOptions reads validity options from the query string: normalize=true
+// ignores formatting-only changes to cited code, and working_tree=true flags
+// evidence whose cited lines have uncommitted edits as dirty. ref checks
+// against a branch, tag or commit instead of HEAD. at does the same as of a
+// point in history, where evidence recorded later did not exist yet; it
+// takes precedence over ref. Uncommitted edits are made against HEAD, so
+// working_tree cannot be combined with ref or at.
+func checkOptions(r *http.Request) graph.CheckOptions {
+	normalize, _ := strconv.ParseBool(r.URL.Query().Get("normalize"))
+	workingTree, _ := strconv.ParseBool(r.URL.Query().Get("working_tree"))
+	opts := graph.CheckOptions{Normalize: normalize, WorkingTree: workingTree, Ref: r.URL.Query().Get("ref")}
+	if at := r.URL.Query().Get("at"); at != "" {
+		opts.Ref, opts.AsOf = at, true
+	}
+	return opts
+}
+
+// snippet returns the cited lines of an evidence node at its commit and at
+// opts.Ref or HEAD, or nil if the checker cannot read file contents or the
+// lines cannot be read.
+func (h *Handler) snippet(g *graph.Graph, evidenceID string, opts graph.CheckOptions) *graph.Snippet {
+	checker, err := graph.ScopeChecker(h.checker, opts.Ref)
+	if err != nil {
+		return nil
+	}
+	reader, ok := graph.Capability[graph.RevisionReader](checker)
+	if !ok {
+		return nil
+	}
+	snippet, _ := g.GetSnippet(evidenceID, reader)
+	return snippet
+}
+
+// evidenceTally counts the evidence of one kind and how much of it is valid.
+type evidenceTally struct {
+	Total int `json:"total"`
+	Valid int `json:"valid"`
+}
+
+// evidenceSummary weighs the support for a claim against its refutation.
+type evidenceSummary struct {
+	Supports evidenceTally `json:"supports"`
+	Refutes  evidenceTally `json:"refutes"`
+	Context  evidenceTally `json:"context"`
+}
+
+func summarize(evidence []evidenceWithValidity) evidenceSummary {
+	var s evidenceSummary
+	for _, ev := range evidence {
+		var t *evidenceTally
+		switch ev.Kind {
+		case graph.EdgeRefutes:
+			t = &s.Refutes
+		case graph.EdgeContext:
+			t = &s.Context
+		default:
+			t = &s.Supports
+		}
+		t.Total++
+		if ev.Valid {
+			t.Valid++
+		}
+	}
+	return s
 }
 
 func (h *Handler) getClaim(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	g := h.store.Graph()
+	g := h.store.Snapshot()
 
 	claim := g.GetClaim(id)
 	if claim == nil {
@@ -95,30 +263,412 @@ func (h *Handler) getClaim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rawEvidence := g.GetEvidenceForClaim(id)
-	evidence := make([]evidenceWithValidity, 0, len(rawEvidence))
-	for _, ev := range rawEvidence {
-		valid, _ := g.CheckEvidence(ev.ID, h.checker)
-		evidence = append(evidence, evidenceWithValidity{EvidenceNode: ev, Valid: valid})
+	evidence, err := h.checkClaimEvidence(g, id, checkOptions(r), includes(r, "snippet"))
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
 	}
-
 	resp := struct {
 		*graph.ClaimNode
 		Evidence []evidenceWithValidity `json:"evidence"`
+		Summary  evidenceSummary        `json:"summary"`
 	}{
 		ClaimNode: claim,
 		Evidence:  evidence,
+		Summary:   summarize(evidence),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkClaimEvidence returns the evidence linked to a claim along with the
+// current validity of each node, and optionally the cited lines. Failed git
+// checks leave evidence invalid, except that a ref which cannot be resolved
+// is returned as an error.
+func (h *Handler) checkClaimEvidence(g *graph.Graph, claimID string, opts graph.CheckOptions, withSnippets bool) ([]evidenceWithValidity, error) {
+	edges := g.GetEdgesForClaim(claimID)
+	validity, err := h.checkEvidence(g, edges, opts)
+	if err != nil {
+		return nil, err
+	}
+	return h.linkedEvidence(g, edges, validity, opts, withSnippets), nil
+}
+
+// checkEvidence checks the evidence of edges with CheckEvidenceByRepo,
+// returning an error for the first failed check when opts names a ref.
+func (h *Handler) checkEvidence(g *graph.Graph, edges []graph.Edge, opts graph.CheckOptions) (map[string]graph.Validity, error) {
+	ids := make([]string, len(edges))
+	for i, e := range edges {
+		ids[i] = e.EvidenceID
+	}
+	validity, errs := g.CheckEvidenceByRepo(ids, h.checker, opts)
+	if opts.Ref != "" {
+		for _, id := range ids {
+			if err := errs[id]; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return validity, nil
+}
+
+// linkedEvidence returns the evidence of edges with its validity, and
+// optionally the cited lines.
+func (h *Handler) linkedEvidence(g *graph.Graph, edges []graph.Edge, validity map[string]graph.Validity, opts graph.CheckOptions, withSnippets bool) []evidenceWithValidity {
+	evidence := make([]evidenceWithValidity, 0, len(edges))
+	for _, edge := range edges {
+		ev := evidenceWithValidity{
+			EvidenceNode:   g.GetEvidence(edge.EvidenceID),
+			Validity:       validity[edge.EvidenceID],
+			Kind:           edge.Kind,
+			LinkProvenance: edge.Provenance,
+		}
+		if withSnippets {
+			ev.Snippet = h.snippet(g, edge.EvidenceID, opts)
+		}
+		evidence = append(evidence, ev)
+	}
+	return evidence
+}
+
+// blamedEvidence is invalid evidence along with the commit that first
+// changed its cited lines, or why that could not be found.
+type blamedEvidence struct {
+	evidenceWithValidity
+	Change *graph.Change `json:"change,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// blameClaim finds, for each piece of a claim's evidence that is no longer
+// valid, the first commit after its recorded one that changed the cited
+// lines, with its author and message.
+func (h *Handler) blameClaim(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	g := h.store.Snapshot()
+
+	claim := g.GetClaim(id)
+	if claim == nil {
+		http.Error(w, `{"error": "claim not found"}`, http.StatusNotFound)
+		return
+	}
+	opts := checkOptions(r)
+	checker, err := graph.ScopeChecker(h.checker, opts.Ref)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	if _, ok := graph.Capability[graph.LineBlamer](checker); !ok {
+		writeError(w, fmt.Errorf("blame is not supported by this server"), http.StatusNotImplemented)
+		return
+	}
+	evidence, err := h.checkClaimEvidence(g, id, opts, false)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	blamed := []blamedEvidence{}
+	for _, ev := range evidence {
+		if ev.Valid || ev.Status == graph.EvidenceNotInHistory {
+			continue
+		}
+		b := blamedEvidence{evidenceWithValidity: ev}
+		if b.Change, err = g.Blame(ev.ID, checker); err != nil {
+			b.Error = err.Error()
+		}
+		blamed = append(blamed, b)
+	}
+	resp := struct {
+		*graph.ClaimNode
+		Evidence []blamedEvidence `json:"evidence"`
+	}{
+		ClaimNode: claim,
+		Evidence:  blamed,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type claimReport struct {
+	*graph.ClaimNode
+	Valid    bool                   `json:"valid"`
+	Evidence []evidenceWithValidity `json:"evidence"`
+	Summary  evidenceSummary        `json:"summary"`
+}
+
+// deletedEvidence is evidence whose cited file no longer exists, along with
+// the claims that cite it.
+type deletedEvidence struct {
+	*graph.EvidenceNode
+	DeletedIn string   `json:"deleted_in"`
+	ClaimIDs  []string `json:"claim_ids"`
+}
+
+// repoReport tallies the evidence checked in one registered repository.
+type repoReport struct {
+	*graph.Repo
+	Valid   int `json:"valid"`
+	Invalid int `json:"invalid"`
+}
+
+// validate checks the evidence of every claim in scope. The scope is narrowed
+// with one or more tag query parameters, and with path parameters to the
+// claims citing those files or files inside those directories; a claim is
+// valid when none of its evidence is invalid. Evidence citing deleted files
+// is also listed on its own so it can be pruned or re-pointed.
+func (h *Handler) validate(w http.ResponseWriter, r *http.Request) {
+	g := h.store.Snapshot()
+	claims := g.ClaimsWithTags(r.URL.Query()["tag"]...)
+	if paths := r.URL.Query()["path"]; len(paths) > 0 {
+		cited := claims[:0]
+		for _, c := range claims {
+			if g.ClaimCites(c.ID, paths...) {
+				cited = append(cited, c)
+			}
+		}
+		claims = cited
+	}
+	opts := checkOptions(r)
+
+	// Check the evidence of every claim at once, so it is grouped by
+	// repository and checked once even when several claims share it.
+	edges := map[string][]graph.Edge{}
+	var all []graph.Edge
+	for _, c := range claims {
+		edges[c.ID] = g.GetEdgesForClaim(c.ID)
+		all = append(all, edges[c.ID]...)
+	}
+	validity, err := h.checkEvidence(g, all, opts)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	reports := make([]claimReport, 0, len(claims))
+	invalid := 0
+	deleted := []*deletedEvidence{}
+	deletedByID := map[string]*deletedEvidence{}
+	repos := []*repoReport{}
+	repoByID := map[string]*repoReport{}
+	checked := map[string]bool{}
+	for _, c := range claims {
+		evidence := h.linkedEvidence(g, edges[c.ID], validity, opts, false)
+		report := claimReport{ClaimNode: c, Valid: true, Evidence: evidence}
+		report.Summary = summarize(report.Evidence)
+		for _, ev := range report.Evidence {
+			if !ev.Valid {
+				report.Valid = false
+			}
+			if ev.Status == graph.EvidenceDeleted {
+				d, ok := deletedByID[ev.ID]
+				if !ok {
+					d = &deletedEvidence{EvidenceNode: ev.EvidenceNode, DeletedIn: ev.DeletedIn}
+					deletedByID[ev.ID] = d
+					deleted = append(deleted, d)
+				}
+				d.ClaimIDs = append(d.ClaimIDs, c.ID)
+			}
+			if repo := g.Repos[ev.Repo]; repo != nil && !checked[ev.ID] {
+				rr, ok := repoByID[repo.ID]
+				if !ok {
+					rr = &repoReport{Repo: repo}
+					repoByID[repo.ID] = rr
+					repos = append(repos, rr)
+				}
+				if ev.Valid {
+					rr.Valid++
+				} else {
+					rr.Invalid++
+				}
+			}
+			checked[ev.ID] = true
+		}
+		if !report.Valid {
+			invalid++
+		}
+		reports = append(reports, report)
+	}
+
+	resp := struct {
+		Claims  []claimReport      `json:"claims"`
+		Valid   int                `json:"valid"`
+		Invalid int                `json:"invalid"`
+		Deleted []*deletedEvidence `json:"deleted"`
+		Repos   []*repoReport      `json:"repos"`
+	}{
+		Claims:  reports,
+		Valid:   len(reports) - invalid,
+		Invalid: invalid,
+		Deleted: deleted,
+		Repos:   repos,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// impact lists the claims whose evidence cites lines a patch changes, before
+// the patch is committed. The patch is a unified diff with paths relative to
+// root, the checkout it applies to, and applies to base, or HEAD when base
+// is empty. Evidence that could not be checked is listed as unchecked.
+func (h *Handler) impact(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Patch string `json:"patch"`
+		Root  string `json:"root"`
+		Base  string `json:"base"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(req.Root) {
+		http.Error(w, `{"error": "root must be an absolute path"}`, http.StatusBadRequest)
+		return
+	}
+
+	g := h.store.Snapshot()
+	claims, unchecked := g.Impact([]byte(req.Patch), filepath.Clean(req.Root), h.checker, graph.CheckOptions{Ref: req.Base})
+	evidence := map[string]bool{}
+	for _, c := range claims {
+		for _, ev := range c.Evidence {
+			evidence[ev.ID] = true
+		}
+	}
+	resp := struct {
+		Claims    []graph.ImpactedClaim     `json:"claims"`
+		Evidence  int                       `json:"evidence"`
+		Unchecked []graph.UncheckedEvidence `json:"unchecked"`
+	}{
+		Claims:    claims,
+		Evidence:  len(evidence),
+		Unchecked: unchecked,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// createRepo registers a repository by the path of its checkout. When the
+// checker can identify repositories, the ID is taken from the checkout, and
+// the default branch is detected unless given.
+func (h *Handler) createRepo(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID            string `json:"id"`
+		Path          string `json:"path"`
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsAbs(req.Path) {
+		http.Error(w, `{"error": "path must be absolute"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, root := req.ID, filepath.Clean(req.Path)
+	if identifier, ok := graph.Capability[graph.RepoIdentifier](h.checker); ok {
+		repo, rel, err := identifier.RepoIdentity(root)
+		if err != nil {
+			writeError(w, fmt.Errorf("%s is not in a git repository", req.Path), http.StatusBadRequest)
+			return
+		}
+		if id != "" && id != repo {
+			writeError(w, fmt.Errorf("%s is a checkout of %s, not %s", req.Path, repo, id), http.StatusBadRequest)
+			return
+		}
+		id = repo
+		if rel != "." {
+			root = strings.TrimSuffix(root, string(filepath.Separator)+filepath.FromSlash(rel))
+		}
+	}
+	branch := req.DefaultBranch
+	if resolver, ok := graph.Capability[graph.BranchResolver](h.checker); ok && branch == "" {
+		branch, _ = resolver.DefaultBranch(root)
+	}
+
+	var repo *graph.Repo
+	var registerErr error
+	h.store.WithGraph(func(g *graph.Graph) {
+		if repo, registerErr = g.RegisterRepo(id, root, branch); registerErr == nil {
+			// Evidence already recorded for the repository now cites files
+			// in this checkout.
+			g.ResolveRepoPaths(map[string]string{repo.ID: repo.Path})
+		}
+	})
+	if registerErr != nil {
+		writeError(w, registerErr, http.StatusBadRequest)
+		return
+	}
+	h.store.Save()
+	h.watchRepo(repo.Path)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(repo)
+}
+
+func (h *Handler) listRepos(w http.ResponseWriter, r *http.Request) {
+	repos := h.store.Snapshot().ListRepos()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(repos)
+}
+
+func (h *Handler) reviewClaim(w http.ResponseWriter, r *http.Request) {
+	claimID := r.PathValue("id")
+
+	var req struct {
+		Status   string `json:"status"`
+		Reviewer string `json:"reviewer"`
+		Comment  string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Reviewer == "" {
+		req.Reviewer = r.Header.Get("X-Trees-Author")
+	}
+
+	var claim *graph.ClaimNode
+	var reviewErr error
+	h.store.WithGraph(func(g *graph.Graph) {
+		claim = g.GetClaim(claimID)
+		if claim == nil {
+			return
+		}
+		_, reviewErr = g.ReviewClaim(claimID, req.Status, req.Reviewer, req.Comment)
+	})
+	if claim == nil {
+		http.Error(w, `{"error": "claim not found"}`, http.StatusNotFound)
+		return
+	}
+	if reviewErr != nil {
+		writeError(w, reviewErr, http.StatusBadRequest)
+		return
+	}
+	h.store.Save()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claim)
+}
+
+// writeError writes err as a JSON error body with the given status code.
+func writeError(w http.ResponseWriter, err error, code int) {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	http.Error(w, string(data), code)
+}
+
 func (h *Handler) linkEvidence(w http.ResponseWriter, r *http.Request) {
 	claimID := r.PathValue("id")
 
 	var req struct {
-		EvidenceID string `json:"evidence_id"`
+		EvidenceID string            `json:"evidence_id"`
+		Kind       string            `json:"kind"`
+		Provenance *graph.Provenance `json:"provenance"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
@@ -126,11 +676,20 @@ func (h *Handler) linkEvidence(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var linkErr error
+	linkStatus := http.StatusBadRequest
 	h.store.WithGraph(func(g *graph.Graph) {
-		linkErr = g.LinkEvidence(claimID, req.EvidenceID)
+		if g.GetClaim(claimID) == nil || g.GetEvidence(req.EvidenceID) == nil {
+			linkStatus = http.StatusNotFound
+		}
+		linkErr = g.AddEdge(graph.Edge{
+			ClaimID:    claimID,
+			EvidenceID: req.EvidenceID,
+			Kind:       req.Kind,
+			Provenance: provenanceFrom(r, req.Provenance),
+		})
 	})
 	if linkErr != nil {
-		http.Error(w, `{"error": "`+linkErr.Error()+`"}`, http.StatusNotFound)
+		writeError(w, linkErr, linkStatus)
 		return
 	}
 	h.store.Save()
@@ -141,32 +700,115 @@ func (h *Handler) linkEvidence(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) createEvidence(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		FilePath  string `json:"file_path"`
-		LineRef   string `json:"line_ref"`
-		GitCommit string `json:"git_commit"`
+		FilePath   string            `json:"file_path"`
+		Repo       string            `json:"repo"`
+		Path       string            `json:"path"`
+		LineRef    string            `json:"line_ref"`
+		Symbol     string            `json:"symbol"`
+		GitCommit  string            `json:"git_commit"`
+		Provenance *graph.Provenance `json:"provenance"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
 		return
 	}
 
+	// A file is cited by its absolute path here, or by its repository and
+	// path within it, resolved through the checkout registered for the
+	// repository.
+	if req.Repo != "" || req.Path != "" {
+		if req.FilePath != "" {
+			http.Error(w, `{"error": "give either file_path or repo and path, not both"}`, http.StatusBadRequest)
+			return
+		}
+		var resolveErr error
+		h.store.ReadGraph(func(g *graph.Graph) {
+			req.FilePath, resolveErr = g.CheckoutFile(req.Repo, req.Path)
+		})
+		if resolveErr != nil {
+			writeError(w, resolveErr, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Symbol == "" {
+		if _, err := graph.ParseLineRef(req.LineRef); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	commit, err := graph.VerifyCitation(h.checker, req.FilePath, req.LineRef, req.GitCommit)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	req.GitCommit = commit
+
+	repo, relPath, branch := req.Repo, req.Path, ""
+	if identifier, ok := graph.Capability[graph.RepoIdentifier](h.checker); ok {
+		id, rel, err := identifier.RepoIdentity(req.FilePath)
+		if err == nil && repo != "" && id != repo {
+			writeError(w, fmt.Errorf("the checkout registered for %s is a checkout of %s", repo, id), http.StatusBadRequest)
+			return
+		}
+		if repo == "" {
+			repo, relPath = id, rel
+		}
+	}
+	known := false
+	h.store.ReadGraph(func(g *graph.Graph) {
+		_, known = g.Repos[repo]
+	})
+	if resolver, ok := graph.Capability[graph.BranchResolver](h.checker); ok && repo != "" && !known {
+		branch, _ = resolver.DefaultBranch(req.FilePath)
+	}
+
 	var ev *graph.EvidenceNode
+	var created graph.EvidenceNode
+	var symbolErr error
+	var newRepo string
 	h.store.WithGraph(func(g *graph.Graph) {
-		ev = g.AddEvidence(req.FilePath, req.LineRef, req.GitCommit)
+		if req.Symbol != "" {
+			reader, ok := graph.Capability[graph.RevisionReader](h.checker)
+			if !ok {
+				symbolErr = fmt.Errorf("symbol evidence is not supported by this server")
+				return
+			}
+			ev, symbolErr = g.AddSymbolEvidence(req.FilePath, req.Symbol, req.GitCommit, reader)
+		} else {
+			ev = g.AddEvidence(req.FilePath, req.LineRef, req.GitCommit)
+		}
+		if ev != nil {
+			ev.Repo, ev.Path = repo, relPath
+			ev.Provenance = provenanceFrom(r, req.Provenance)
+			if _, known := g.Repos[repo]; repo != "" && !known {
+				if registered, err := g.RegisterRepo(repo, ev.CheckoutRoot(), branch); err == nil {
+					newRepo = registered.Path
+				}
+			}
+			// Respond with a copy, since the background checker may record
+			// a check on the node once the lock is released.
+			created = *ev
+		}
 	})
+	if symbolErr != nil {
+		writeError(w, symbolErr, http.StatusBadRequest)
+		return
+	}
 	if ev == nil {
 		http.Error(w, `{"error": "file_path must be absolute and git_commit is required"}`, http.StatusBadRequest)
 		return
 	}
 	h.store.Save()
+	h.watchRepo(newRepo)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(ev)
+	json.NewEncoder(w).Encode(&created)
 }
 
 func (h *Handler) listEvidence(w http.ResponseWriter, r *http.Request) {
-	g := h.store.Graph()
+	g := h.store.Snapshot()
 	evidence := make([]*graph.EvidenceNode, 0, len(g.Evidence))
 	for _, e := range g.Evidence {
 		evidence = append(evidence, e)
@@ -178,7 +820,7 @@ func (h *Handler) listEvidence(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) getEvidence(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	g := h.store.Graph()
+	g := h.store.Snapshot()
 
 	ev := g.GetEvidence(id)
 	if ev == nil {
@@ -186,16 +828,82 @@ func (h *Handler) getEvidence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	valid, _ := g.CheckEvidence(id, h.checker)
+	opts := checkOptions(r)
+	validity, err := g.EvidenceValidity(id, h.checker, opts)
+	if err != nil && opts.Ref != "" {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
 
-	resp := struct {
-		*graph.EvidenceNode
-		Valid bool `json:"valid"`
-	}{
+	resp := evidenceWithValidity{
 		EvidenceNode: ev,
-		Valid:        valid,
+		Validity:     validity,
+	}
+	if includes(r, "snippet") {
+		resp.Snippet = h.snippet(g, id, opts)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// evidenceTimeline returns the history of an evidence node: its creation,
+// each status change found while watching its repository, and re-anchors.
+func (h *Handler) evidenceTimeline(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	timeline, err := h.store.Snapshot().Timeline(id)
+	if err != nil {
+		http.Error(w, `{"error": "evidence not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+func (h *Handler) deleteEvidence(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var removeErr error
+	h.store.WithGraph(func(g *graph.Graph) {
+		removeErr = g.RemoveEvidence(id)
+	})
+	if removeErr != nil {
+		http.Error(w, `{"error": "evidence not found"}`, http.StatusNotFound)
+		return
+	}
+	h.store.Save()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
+
+// reanchorEvidence moves still-valid evidence to its current path, lines and
+// HEAD commit, e.g. after the cited file was renamed.
+func (h *Handler) reanchorEvidence(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var ev *graph.EvidenceNode
+	var reanchorErr error
+	found := true
+	h.store.WithGraph(func(g *graph.Graph) {
+		if g.GetEvidence(id) == nil {
+			found = false
+			return
+		}
+		ev, reanchorErr = g.Reanchor(id, h.checker, checkOptions(r))
+	})
+	if !found {
+		http.Error(w, `{"error": "evidence not found"}`, http.StatusNotFound)
+		return
+	}
+	if reanchorErr != nil {
+		writeError(w, reanchorErr, http.StatusConflict)
+		return
+	}
+	h.store.Save()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ev)
+}
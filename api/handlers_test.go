@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -334,3 +335,1321 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Errorf("expected body %q, got %q", "OK", w.Body.String())
 	}
 }
+
+func TestCreateClaimWithTags(t *testing.T) {
+	h := newTestHandler(t)
+	body := `{"content": "Tokens expire after an hour", "tags": ["perf", "auth"]}`
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	tags, ok := resp["tags"].([]interface{})
+	if !ok || len(tags) != 2 || tags[0] != "auth" || tags[1] != "perf" {
+		t.Errorf("expected tags [auth perf], got %v", resp["tags"])
+	}
+}
+
+func TestListClaimsFilteredByTag(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, body := range []string{
+		`{"content": "claim one", "tags": ["auth"]}`,
+		`{"content": "claim two", "tags": ["auth", "perf"]}`,
+		`{"content": "claim three"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/claims?tag=auth", nil)
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp []map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp) != 2 {
+		t.Errorf("expected 2 claims tagged auth, got %d", len(resp))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims?tag=auth&tag=perf", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	resp = nil
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp) != 1 || resp[0]["content"] != "claim two" {
+		t.Errorf("expected only claim two, got %v", resp)
+	}
+}
+
+func TestValidateScopedByTag(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{changed: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "tagged", "tags": ["auth"]}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "untagged"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1-5", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/validate?tag=auth", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var resp struct {
+		Claims []struct {
+			ID    string `json:"id"`
+			Valid bool   `json:"valid"`
+		} `json:"claims"`
+		Valid   int `json:"valid"`
+		Invalid int `json:"invalid"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	if len(resp.Claims) != 1 || resp.Claims[0].ID != claimID {
+		t.Fatalf("expected only the tagged claim, got %+v", resp.Claims)
+	}
+	if resp.Claims[0].Valid {
+		t.Error("expected claim with changed evidence to be invalid")
+	}
+	if resp.Valid != 0 || resp.Invalid != 1 {
+		t.Errorf("expected 0 valid and 1 invalid, got %d and %d", resp.Valid, resp.Invalid)
+	}
+}
+
+func TestValidateScopedByPath(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{changed: true})
+
+	claimIDs := map[string]string{}
+	for _, file := range []string{"/home/user/pkg/f.go", "/home/user/g.go"} {
+		req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "about `+file+`"}`))
+		w := httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		var claim map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&claim)
+		claimIDs[file] = claim["id"].(string)
+
+		req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "`+file+`", "line_ref": "1", "git_commit": "abc123"}`))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		var ev map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&ev)
+		req = httptest.NewRequest(http.MethodPost, "/claims/"+claimIDs[file]+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+		h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"path=/home/user/g.go", []string{claimIDs["/home/user/g.go"]}},
+		{"path=/home/user/pkg", []string{claimIDs["/home/user/pkg/f.go"]}},
+		{"path=/home/user/h.go", nil},
+		{"path=/home/user/pkg/f.go&path=/home/user/g.go", []string{claimIDs["/home/user/pkg/f.go"], claimIDs["/home/user/g.go"]}},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/validate?"+tt.query, nil)
+		w := httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tt.query, w.Code)
+		}
+		var resp struct {
+			Claims []struct {
+				ID string `json:"id"`
+			} `json:"claims"`
+			Invalid int `json:"invalid"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		var got []string
+		for _, c := range resp.Claims {
+			got = append(got, c.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || resp.Invalid != len(tt.want) {
+			t.Errorf("%s: expected claims %v all invalid, got %v with %d invalid", tt.query, tt.want, got, resp.Invalid)
+		}
+	}
+}
+
+func TestCreateClaimRecordsProvenance(t *testing.T) {
+	h := newTestHandler(t)
+	body := `{"content": "Tokens are cached", "provenance": {"source": "read auth/cache.go", "author": "agent-7"}}`
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(body))
+	req.Header.Set("X-Trees-Author", "alice")
+	req.Header.Set("X-Trees-Session", "session-42")
+	req.Header.Set("X-Trees-Tool", "trees-cli")
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp struct {
+		Provenance graph.Provenance `json:"provenance"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	want := graph.Provenance{Author: "agent-7", Session: "session-42", Tool: "trees-cli", Source: "read auth/cache.go"}
+	if resp.Provenance != want {
+		t.Errorf("expected provenance %+v, got %+v", want, resp.Provenance)
+	}
+}
+
+func TestCreateClaimWithoutProvenance(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "anonymous"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if _, ok := resp["provenance"]; ok {
+		t.Errorf("expected no provenance, got %v", resp["provenance"])
+	}
+}
+
+func TestListClaimsFilteredBySession(t *testing.T) {
+	h := newTestHandler(t)
+
+	for _, session := range []string{"session-1", "session-1", "session-2", ""} {
+		req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "claim"}`))
+		if session != "" {
+			req.Header.Set("X-Trees-Session", session)
+		}
+		h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/claims?session=session-1", nil)
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp []map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp) != 2 {
+		t.Errorf("expected 2 claims from session-1, got %d", len(resp))
+	}
+}
+
+func TestLinkEvidenceRecordsProvenance(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "test"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1-5", "git_commit": "abc123"}`))
+	req.Header.Set("X-Trees-Author", "agent-7")
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	req.Header.Set("X-Trees-Author", "bob")
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp struct {
+		Evidence []struct {
+			Provenance     graph.Provenance `json:"provenance"`
+			LinkProvenance graph.Provenance `json:"link_provenance"`
+		} `json:"evidence"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Evidence) != 1 {
+		t.Fatalf("expected 1 evidence, got %d", len(resp.Evidence))
+	}
+	if resp.Evidence[0].Provenance.Author != "agent-7" {
+		t.Errorf("expected evidence author %q, got %q", "agent-7", resp.Evidence[0].Provenance.Author)
+	}
+	if resp.Evidence[0].LinkProvenance.Author != "bob" {
+		t.Errorf("expected link author %q, got %q", "bob", resp.Evidence[0].LinkProvenance.Author)
+	}
+}
+
+func TestReviewClaim(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "test"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+	if claim["status"] != "draft" {
+		t.Errorf("expected status draft, got %v", claim["status"])
+	}
+
+	body := `{"status": "verified", "comment": "read the code"}`
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/review", strings.NewReader(body))
+	req.Header.Set("X-Trees-Author", "alice")
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Status  string         `json:"status"`
+		Reviews []graph.Review `json:"reviews"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Status != "verified" {
+		t.Errorf("expected status verified, got %q", resp.Status)
+	}
+	if len(resp.Reviews) != 1 || resp.Reviews[0].Reviewer != "alice" || resp.Reviews[0].Comment != "read the code" {
+		t.Errorf("unexpected reviews %+v", resp.Reviews)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims?status=verified", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var list []map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 {
+		t.Errorf("expected 1 verified claim, got %d", len(list))
+	}
+}
+
+func TestReviewClaimErrors(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims/nonexistent/review", strings.NewReader(`{"status": "verified", "reviewer": "alice"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "test"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	for _, body := range []string{
+		`{"status": "approved", "reviewer": "alice"}`,
+		`{"status": "verified"}`,
+		`not json`,
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/review", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestGetClaimSummarizesSupportAndRefutation(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "Tokens never expire"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	for _, kind := range []string{"supports", "refutes", "refutes", "context", ""} {
+		req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1-5", "git_commit": "abc123"}`))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		var ev map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&ev)
+
+		body := `{"evidence_id": "` + ev["id"].(string) + `", "kind": "` + kind + `"}`
+		req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("kind %q: expected status %d, got %d: %s", kind, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp struct {
+		Evidence []struct {
+			Kind string `json:"kind"`
+		} `json:"evidence"`
+		Summary map[string]struct {
+			Total int `json:"total"`
+			Valid int `json:"valid"`
+		} `json:"summary"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	if len(resp.Evidence) != 5 || resp.Evidence[1].Kind != "refutes" {
+		t.Errorf("expected kinds on linked evidence, got %+v", resp.Evidence)
+	}
+	if got := resp.Summary["supports"]; got.Total != 2 || got.Valid != 2 {
+		t.Errorf("expected 2 valid supporting, got %+v", got)
+	}
+	if got := resp.Summary["refutes"]; got.Total != 2 || got.Valid != 2 {
+		t.Errorf("expected 2 valid refuting, got %+v", got)
+	}
+	if got := resp.Summary["context"]; got.Total != 1 {
+		t.Errorf("expected 1 context, got %+v", got)
+	}
+}
+
+func TestLinkEvidenceUnknownKind(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "test"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1-5", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+
+	body := `{"evidence_id": "` + ev["id"].(string) + `", "kind": "contradicts"}`
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claim["id"].(string)+"/evidence", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// mockRevisionChecker is a mockGitChecker that can also read file contents,
+// keyed by "rev:filepath".
+type mockRevisionChecker struct {
+	mockGitChecker
+	files map[string]string
+}
+
+func (m *mockRevisionChecker) ReadFileAt(rev, filePath string) ([]byte, error) {
+	content, ok := m.files[rev+":"+filePath]
+	if !ok {
+		return nil, fmt.Errorf("path %q does not exist in %q", filePath, rev)
+	}
+	return []byte(content), nil
+}
+
+func TestGetClaimIncludesSnippets(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRevisionChecker{files: map[string]string{
+		"abc123:/home/user/f.go": "package f\n\nfunc A() {}\n",
+		"HEAD:/home/user/f.go":   "package f\n\nfunc B() {}\n",
+	}})
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "test"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "3", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	evID := ev["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+evID+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "snippet") {
+		t.Errorf("expected no snippet without include, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID+"?include=snippet", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp struct {
+		Evidence []struct {
+			Snippet graph.Snippet `json:"snippet"`
+		} `json:"evidence"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Evidence) != 1 {
+		t.Fatalf("expected 1 evidence, got %d", len(resp.Evidence))
+	}
+	snippet := resp.Evidence[0].Snippet
+	if len(snippet.AtCommit) != 1 || snippet.AtCommit[0].Text != "func A() {}" {
+		t.Errorf("unexpected lines at commit %+v", snippet.AtCommit)
+	}
+	if len(snippet.AtHead) != 1 || snippet.AtHead[0].Text != "func B() {}" {
+		t.Errorf("unexpected lines at HEAD %+v", snippet.AtHead)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID+"?include=snippet", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var evResp struct {
+		Snippet graph.Snippet `json:"snippet"`
+	}
+	json.NewDecoder(w.Body).Decode(&evResp)
+	if len(evResp.Snippet.AtCommit) != 1 {
+		t.Errorf("expected snippet on evidence, got %+v", evResp.Snippet)
+	}
+}
+
+func TestCreateSymbolEvidence(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRevisionChecker{files: map[string]string{
+		"abc123:/home/user/f.go": "package f\n\n// A does nothing.\nfunc A() {\n}\n",
+	}})
+
+	body := `{"file_path": "/home/user/f.go", "symbol": "f.A", "git_commit": "abc123"}`
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["line_ref"] != "4-5" {
+		t.Errorf("expected line_ref 4-5, got %v", resp["line_ref"])
+	}
+	if resp["symbol"] != "f.A" {
+		t.Errorf("expected symbol f.A, got %v", resp["symbol"])
+	}
+
+	body = `{"file_path": "/home/user/f.go", "symbol": "f.Missing", "git_commit": "abc123"}`
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for missing symbol, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateSymbolEvidenceUnsupported(t *testing.T) {
+	h := newTestHandler(t)
+
+	body := `{"file_path": "/home/user/f.go", "symbol": "f.A", "git_commit": "abc123"}`
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestGetEvidenceNormalized(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: true},
+		files: map[string]string{
+			"abc123:/home/user/f.go": "package f\n\nfunc A() int { return 1 }\n",
+			"HEAD:/home/user/f.go":   "package f\n\n// A returns one.\nfunc A() int {\n\treturn 1\n}\n",
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "3", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var created map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&created)
+	id := created["id"].(string)
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+id, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["valid"] != false {
+		t.Errorf("expected valid=false without normalization, got %v", resp["valid"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+id+"?normalize=true", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	resp = nil
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["valid"] != true {
+		t.Errorf("expected valid=true with normalization, got %v", resp["valid"])
+	}
+	if resp["current_line_ref"] != "4-6" {
+		t.Errorf("expected current_line_ref 4-6, got %v", resp["current_line_ref"])
+	}
+}
+
+// mockRenameChecker is a mockRevisionChecker that also follows renames and
+// reports a fixed HEAD commit.
+type mockRenameChecker struct {
+	mockRevisionChecker
+	renames map[string]string
+	head    string
+}
+
+func (m *mockRenameChecker) CurrentPath(commit, filePath string) (string, error) {
+	if p, ok := m.renames[filePath]; ok {
+		return p, nil
+	}
+	return filePath, nil
+}
+
+func (m *mockRenameChecker) HeadCommit(filePath string) (string, error) {
+	return m.head, nil
+}
+
+func TestReanchorRenamedEvidence(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			mockGitChecker: mockGitChecker{changed: true},
+			files: map[string]string{
+				"abc123:/home/user/old.go": "package f\n",
+				"HEAD:/home/user/new.go":   "package f\n",
+			},
+		},
+		renames: map[string]string{"/home/user/old.go": "/home/user/new.go"},
+		head:    "def456",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/old.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var created map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&created)
+	id := created["id"].(string)
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+id, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["valid"] != true || resp["current_path"] != "/home/user/new.go" {
+		t.Errorf("expected valid evidence at new.go, got valid=%v current_path=%v", resp["valid"], resp["current_path"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence/"+id+"/reanchor", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	resp = nil
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["file_path"] != "/home/user/new.go" || resp["git_commit"] != "def456" {
+		t.Errorf("expected evidence moved to new.go at def456, got %v at %v", resp["file_path"], resp["git_commit"])
+	}
+}
+
+func TestReanchorStaleEvidence(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{changed: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence/nonexistent/reanchor", nil)
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var created map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence/"+created["id"].(string)+"/reanchor", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+// mockDeletionChecker reports every file as deleted in a fixed commit.
+type mockDeletionChecker struct {
+	mockGitChecker
+	deletedIn string
+}
+
+func (m *mockDeletionChecker) DeletingCommit(commit, filePath string) (string, error) {
+	return m.deletedIn, nil
+}
+
+func TestValidateListsDeletedEvidence(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockDeletionChecker{mockGitChecker: mockGitChecker{changed: true}, deletedIn: "def456"})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/gone.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	evID := ev["id"].(string)
+
+	var claimIDs []string
+	for _, content := range []string{"one", "two"} {
+		req = httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "`+content+`"}`))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		var claim map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&claim)
+		claimIDs = append(claimIDs, claim["id"].(string))
+
+		req = httptest.NewRequest(http.MethodPost, "/claims/"+claim["id"].(string)+"/evidence", strings.NewReader(`{"evidence_id": "`+evID+`"}`))
+		h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/validate", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp struct {
+		Claims []struct {
+			Evidence []struct {
+				Status string `json:"status"`
+			} `json:"evidence"`
+		} `json:"claims"`
+		Deleted []struct {
+			ID        string   `json:"id"`
+			DeletedIn string   `json:"deleted_in"`
+			ClaimIDs  []string `json:"claim_ids"`
+		} `json:"deleted"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+
+	if len(resp.Claims) != 2 || resp.Claims[0].Evidence[0].Status != "deleted" {
+		t.Errorf("expected deleted status on claim evidence, got %+v", resp.Claims)
+	}
+	if len(resp.Deleted) != 1 {
+		t.Fatalf("expected 1 deleted evidence, got %d", len(resp.Deleted))
+	}
+	d := resp.Deleted[0]
+	if d.ID != evID || d.DeletedIn != "def456" {
+		t.Errorf("expected %s deleted in def456, got %s deleted in %s", evID, d.ID, d.DeletedIn)
+	}
+	if len(d.ClaimIDs) != 2 {
+		t.Errorf("expected both citing claims, got %v", d.ClaimIDs)
+	}
+}
+
+func TestDeleteEvidence(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	evID := ev["id"].(string)
+
+	req = httptest.NewRequest(http.MethodDelete, "/evidence/"+evID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d after delete, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/evidence/"+evID, nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d deleting twice, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// mockWorkingTreeChecker reports the same uncommitted lines for every file.
+type mockWorkingTreeChecker struct {
+	mockGitChecker
+	uncommitted []graph.LineRange
+}
+
+func (m *mockWorkingTreeChecker) UncommittedLines(filePath string) ([]graph.LineRange, error) {
+	return m.uncommitted, nil
+}
+
+func TestGetEvidenceWorkingTree(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockWorkingTreeChecker{uncommitted: []graph.LineRange{{Start: 12, End: 12}}})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "10-15", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	evID := ev["id"].(string)
+
+	for query, expected := range map[string]string{"": "valid", "?working_tree=true": "dirty"} {
+		req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID+query, nil)
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp["status"] != expected {
+			t.Errorf("query %q: expected status %q, got %v", query, expected, resp["status"])
+		}
+		if resp["valid"] != true {
+			t.Errorf("query %q: expected evidence to stay valid, got %v", query, resp["valid"])
+		}
+	}
+
+	for _, query := range []string{"?working_tree=true&ref=main", "?working_tree=true&at=main"} {
+		req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID+query, nil)
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("query %q: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+// mockRefChecker resolves any ref to a fixed commit and reports every file
+// as changed there.
+type mockRefChecker struct {
+	mockGitChecker
+	ref string
+}
+
+func (m *mockRefChecker) AtRef(ref string) graph.GitChecker {
+	return &mockRefChecker{mockGitChecker: mockGitChecker{changed: true}, ref: ref}
+}
+
+func (m *mockRefChecker) HeadCommit(filePath string) (string, error) {
+	if m.ref == "missing" {
+		return "", fmt.Errorf("unknown revision %q", m.ref)
+	}
+	// Commits resolve to themselves, and refs to def456.
+	if m.ref != "" && strings.Trim(m.ref, "0123456789abcdef") == "" {
+		return m.ref, nil
+	}
+	return "def456", nil
+}
+
+func TestGetEvidenceAtRef(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRefChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	evID := ev["id"].(string)
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID+"?ref=origin/main", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["valid"] != false {
+		t.Errorf("expected evidence to be stale at origin/main, got %v", resp["valid"])
+	}
+	if resp["ref"] != "origin/main" || resp["ref_commit"] != "def456" {
+		t.Errorf("expected ref origin/main at def456, got %v at %v", resp["ref"], resp["ref_commit"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+evID+"?ref=missing", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for unknown ref, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// mockAncestryChecker is a mockRefChecker whose refs contain only abc123,
+// while HEAD contains every commit.
+type mockAncestryChecker struct {
+	mockRefChecker
+}
+
+func (m *mockAncestryChecker) AtRef(ref string) graph.GitChecker {
+	return &mockAncestryChecker{mockRefChecker{mockGitChecker: mockGitChecker{changed: false}, ref: ref}}
+}
+
+func (m *mockAncestryChecker) IsAncestor(ancestor, commit, filePath string) (bool, error) {
+	return ancestor == "abc123" || commit == "HEAD", nil
+}
+
+func TestGetClaimAt(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockAncestryChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	for _, commit := range []string{"abc123", "fff999"} {
+		body := `{"file_path": "/home/user/f.go", "line_ref": "1", "git_commit": "` + commit + `"}`
+		req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		var ev map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&ev)
+		req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+		h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID+"?at=v1.0", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Evidence []struct {
+			GitCommit string `json:"git_commit"`
+			Status    string `json:"status"`
+			Ref       string `json:"ref"`
+			RefCommit string `json:"ref_commit"`
+		} `json:"evidence"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Evidence) != 2 {
+		t.Fatalf("expected 2 evidence nodes, got %d", len(resp.Evidence))
+	}
+	for _, ev := range resp.Evidence {
+		want := graph.EvidenceValid
+		if ev.GitCommit == "fff999" {
+			want = graph.EvidenceNotInHistory
+		}
+		if ev.Status != want || ev.Ref != "v1.0" || ev.RefCommit != "def456" {
+			t.Errorf("expected evidence at %s to be %s as of v1.0 (def456), got %s as of %s (%s)",
+				ev.GitCommit, want, ev.Status, ev.Ref, ev.RefCommit)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID+"?at=missing", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for unknown commit, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestValidateRefUnsupported(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claim["id"].(string)+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/validate?ref=origin/main", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateEvidenceVerifiesCitation(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRevisionChecker{files: map[string]string{
+		"abc123:/home/user/f.go": "package f\n\nfunc F() {}\n",
+	}})
+
+	tests := []struct {
+		body     string
+		code     int
+		contains string
+	}{
+		{`{"file_path": "/home/user/f.go", "line_ref": "1-3", "git_commit": "abc123"}`, http.StatusCreated, ""},
+		{`{"file_path": "/home/user/g.go", "line_ref": "1", "git_commit": "abc123"}`, http.StatusBadRequest, "does not exist at commit abc123"},
+		{`{"file_path": "/home/user/f.go", "line_ref": "3-4", "git_commit": "abc123"}`, http.StatusBadRequest, "line 4 is past the end"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.code, w.Code)
+		}
+		if tt.contains != "" && !strings.Contains(w.Body.String(), tt.contains) {
+			t.Errorf("%s: expected error containing %q, got %s", tt.body, tt.contains, w.Body.String())
+		}
+	}
+}
+
+func TestCreateEvidenceLineRef(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "7,1-3,2-4", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+
+	var ev struct {
+		LineRef    string            `json:"line_ref"`
+		LineRanges []graph.LineRange `json:"line_ranges"`
+	}
+	json.NewDecoder(w.Body).Decode(&ev)
+	if ev.LineRef != "1-4,7" {
+		t.Errorf("expected normalized line ref %q, got %q", "1-4,7", ev.LineRef)
+	}
+	if len(ev.LineRanges) != 2 || ev.LineRanges[0] != (graph.LineRange{Start: 1, End: 4}) {
+		t.Errorf("expected structured ranges, got %v", ev.LineRanges)
+	}
+
+	for _, ref := range []string{"abc", "70-13", ""} {
+		req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "`+ref+`", "git_commit": "abc123"}`))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("line ref %q: expected status %d, got %d", ref, http.StatusBadRequest, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "line reference") {
+			t.Errorf("line ref %q: expected line reference error, got %s", ref, w.Body.String())
+		}
+	}
+}
+
+// mockRepoChecker identifies every file as being in one repository rooted at
+// /home/alice/project.
+type mockRepoChecker struct {
+	mockGitChecker
+}
+
+func (m *mockRepoChecker) RepoIdentity(filePath string) (string, string, error) {
+	rel, err := filepath.Rel("/home/alice/project", filePath)
+	return "root1", filepath.ToSlash(rel), err
+}
+
+func (m *mockRepoChecker) DefaultBranch(filePath string) (string, error) {
+	return "origin/main", nil
+}
+
+func TestCreateEvidenceRecordsRepo(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRepoChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/alice/project/pkg/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	if ev["repo"] != "root1" || ev["path"] != "pkg/f.go" {
+		t.Errorf("expected repo root1 path pkg/f.go, got %v %v", ev["repo"], ev["path"])
+	}
+
+	h.SetCheckouts(map[string]string{"root1": "/home/bob/src/project"})
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+ev["id"].(string), nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&ev)
+	if ev["file_path"] != "/home/bob/src/project/pkg/f.go" {
+		t.Errorf("expected file path in local checkout, got %v", ev["file_path"])
+	}
+}
+
+func TestCreateRepo(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRepoChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"path": "/home/alice/project/pkg"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var repo map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&repo)
+	if repo["id"] != "root1" || repo["path"] != "/home/alice/project" || repo["default_branch"] != "origin/main" {
+		t.Errorf("expected root1 at /home/alice/project on origin/main, got %v", repo)
+	}
+
+	for _, body := range []string{`{"path": "relative"}`, `{"id": "other", "path": "/home/alice/project"}`} {
+		req = httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestCreateEvidenceByRepoPath(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRepoChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/alice/project/pkg/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var existing map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&existing)
+
+	// Registering another checkout re-points the evidence already recorded.
+	req = httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"id": "root1", "path": "/home/bob/src/project"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+existing["id"].(string), nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&existing)
+	if existing["file_path"] != "/home/bob/src/project/pkg/f.go" {
+		t.Errorf("expected file path in the registered checkout, got %v", existing["file_path"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"repo": "root1", "path": "pkg/g.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	if ev["file_path"] != "/home/bob/src/project/pkg/g.go" || ev["repo"] != "root1" || ev["path"] != "pkg/g.go" {
+		t.Errorf("expected pkg/g.go of root1 in /home/bob/src/project, got %v", ev)
+	}
+
+	for _, body := range []string{
+		`{"repo": "root2", "path": "pkg/g.go", "line_ref": "1", "git_commit": "abc123"}`,
+		`{"repo": "root1", "path": "../g.go", "line_ref": "1", "git_commit": "abc123"}`,
+		`{"repo": "root1", "line_ref": "1", "git_commit": "abc123"}`,
+		`{"file_path": "/home/bob/src/project/pkg/g.go", "repo": "root1", "path": "pkg/g.go", "line_ref": "1", "git_commit": "abc123"}`,
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestCreateEvidenceRegistersRepo(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockRepoChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/alice/project/pkg/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claim["id"].(string)+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/repos", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var repos []map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&repos)
+	if len(repos) != 1 || repos[0]["id"] != "root1" || repos[0]["path"] != "/home/alice/project" {
+		t.Fatalf("expected root1 registered at /home/alice/project, got %v", repos)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/validate", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var report struct {
+		Repos []struct {
+			ID      string `json:"id"`
+			Valid   int    `json:"valid"`
+			Invalid int    `json:"invalid"`
+		} `json:"repos"`
+	}
+	json.NewDecoder(w.Body).Decode(&report)
+	if len(report.Repos) != 1 || report.Repos[0].ID != "root1" || report.Repos[0].Valid != 1 {
+		t.Errorf("expected one valid evidence in root1, got %+v", report.Repos)
+	}
+}
+
+// mockBlameChecker is a mockGitChecker that blames every change on a fixed
+// commit.
+type mockBlameChecker struct {
+	mockGitChecker
+	change *graph.Change
+}
+
+func (m *mockBlameChecker) FirstChange(commit, filePath string, lines []graph.LineRange) (*graph.Change, error) {
+	return m.change, nil
+}
+
+func TestBlameClaim(t *testing.T) {
+	checker := &mockBlameChecker{
+		mockGitChecker: mockGitChecker{changed: true},
+		change:         &graph.Change{Commit: "def456", Author: "A", Email: "a@example.com", Message: "rewrite f"},
+	}
+	h := newTestHandlerWithChecker(t, checker)
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/f.go", "line_ref": "1-3", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID+"/blame", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		ID       string `json:"id"`
+		Evidence []struct {
+			ID     string        `json:"id"`
+			Status string        `json:"status"`
+			Change *graph.Change `json:"change"`
+		} `json:"evidence"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.ID != claimID {
+		t.Errorf("expected claim %s, got %s", claimID, resp.ID)
+	}
+	if len(resp.Evidence) != 1 {
+		t.Fatalf("expected 1 evidence node, got %d", len(resp.Evidence))
+	}
+	if e := resp.Evidence[0]; e.Status != graph.EvidenceStale || e.Change == nil || e.Change.Commit != "def456" || e.Change.Author != "A" {
+		t.Errorf("expected stale evidence blamed on def456 by A, got %s %+v", e.Status, e.Change)
+	}
+
+	checker.changed = false
+	req = httptest.NewRequest(http.MethodGet, "/claims/"+claimID+"/blame", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Evidence) != 0 {
+		t.Errorf("expected valid evidence to be left out, got %d", len(resp.Evidence))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/claims/nonexistent/blame", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+
+	// The caching checker the server wraps checkers in supports blame only
+	// if the wrapped checker does.
+	for _, checker := range []graph.GitChecker{&mockGitChecker{}, graph.NewCachingChecker(&mockGitChecker{})} {
+		h = newTestHandlerWithChecker(t, checker)
+		req = httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		json.NewDecoder(w.Body).Decode(&claim)
+		req = httptest.NewRequest(http.MethodGet, "/claims/"+claim["id"].(string)+"/blame", nil)
+		w = httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, req)
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status 501 with %T, got %d", checker, w.Code)
+		}
+	}
+}
+
+func TestImpact(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{})
+
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	claimID := claim["id"].(string)
+
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/pkg/f.go", "line_ref": "10-12", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claimID+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	patch := "--- a/pkg/f.go\n+++ b/pkg/f.go\n@@ -11 +11 @@\n-\told\n+\tnew\n"
+	body, _ := json.Marshal(map[string]string{"patch": patch, "root": "/home/user/project"})
+	req = httptest.NewRequest(http.MethodPost, "/impact", strings.NewReader(string(body)))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Claims []struct {
+			ID       string `json:"id"`
+			Evidence []struct {
+				ID    string   `json:"id"`
+				Hunks []string `json:"hunks"`
+			} `json:"evidence"`
+		} `json:"claims"`
+		Evidence int `json:"evidence"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Claims) != 1 || resp.Claims[0].ID != claimID || resp.Evidence != 1 {
+		t.Fatalf("expected the claim with 1 evidence node, got %+v", resp)
+	}
+	if hunks := resp.Claims[0].Evidence[0].Hunks; len(hunks) != 1 || hunks[0] != "-11,1 +11,1" {
+		t.Errorf("expected hunk -11,1 +11,1, got %v", hunks)
+	}
+
+	body, _ = json.Marshal(map[string]string{"patch": patch, "root": "/home/user/other"})
+	req = httptest.NewRequest(http.MethodPost, "/impact", strings.NewReader(string(body)))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Claims) != 0 {
+		t.Errorf("expected no claims for a patch to another checkout, got %d", len(resp.Claims))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/impact", strings.NewReader(`{"patch": "", "root": "project"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a relative root, got %d", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"trees/graph"
+)
+
+// getGraph returns the whole graph, for sharing it through git.
+func (h *Handler) getGraph(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	h.store.WithGraph(func(g *graph.Graph) {
+		data, err = json.Marshal(g)
+	})
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// mergeGraph merges a graph sent in the body, such as one pulled from a git
+// remote, into the store. See graph.Merge for how conflicts are settled. The
+// graph is rejected whole if any evidence in it fails validation, since
+// anyone who can push to the remote can write it.
+func (h *Handler) mergeGraph(w http.ResponseWriter, r *http.Request) {
+	other := graph.New()
+	if err := json.NewDecoder(r.Body).Decode(other); err != nil {
+		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	other.FillDefaults()
+	if err := other.Validate(); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var stats graph.MergeStats
+	h.store.WithGraph(func(g *graph.Graph) {
+		stats = g.Merge(other)
+	})
+	h.store.Save()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
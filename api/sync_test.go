@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"trees/graph"
+)
+
+func TestGraphMerge(t *testing.T) {
+	h := newTestHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "local claim"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	remote := graph.New()
+	claim := remote.AddClaim("remote claim")
+	ev := remote.AddEvidence("/home/bob/f.go", "1", strings.Repeat("ab", 20))
+	remote.LinkEvidence(claim.ID, ev.ID)
+	body, _ := json.Marshal(remote)
+
+	req = httptest.NewRequest(http.MethodPost, "/graph/merge", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats graph.MergeStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats.ClaimsAdded != 1 || stats.EvidenceAdded != 1 || stats.EdgesAdded != 1 {
+		t.Errorf("expected 1 claim, evidence node and edge added, got %+v", stats)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/graph", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	g := graph.New()
+	if err := json.NewDecoder(w.Body).Decode(g); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.Claims) != 2 || g.GetClaim(claim.ID) == nil || len(g.GetEvidenceForClaim(claim.ID)) != 1 {
+		t.Errorf("expected both claims and the remote evidence, got %d claims", len(g.Claims))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/graph/merge", strings.NewReader("{"))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+
+	// Evidence whose commit would reach git as an option is refused, and
+	// nothing from that graph is merged.
+	bad := graph.New()
+	bad.AddClaim("injected claim")
+	badEv := bad.AddEvidence("/home/bob/f.go", "1", "--output=/tmp/written")
+	body, _ = json.Marshal(bad)
+	req = httptest.NewRequest(http.MethodPost, "/graph/merge", strings.NewReader(string(body)))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+	if h.store.Graph().GetEvidence(badEv.ID) != nil || len(h.store.Graph().Claims) != 2 {
+		t.Errorf("expected nothing from the rejected graph to be merged")
+	}
+}
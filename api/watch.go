@@ -0,0 +1,94 @@
+package api
+
+import (
+	"log"
+	"time"
+	"trees/graph"
+	"trees/watch"
+)
+
+// Watch re-validates the evidence in each registered repository whenever w
+// reports that its refs moved, recording the outcome on every evidence node
+// so that clients can read it without waiting on git. Repositories
+// registered later are watched too. Every repository is validated once at
+// the start.
+func (h *Handler) Watch(w *watch.Watcher) {
+	h.watcher = w
+	var dirs []string
+	h.store.ReadGraph(func(g *graph.Graph) {
+		for _, repo := range g.ListRepos() {
+			dirs = append(dirs, repo.Path)
+		}
+	})
+	for _, dir := range dirs {
+		h.watchRepo(dir)
+	}
+	go func() {
+		for dir := range w.Changes {
+			h.Revalidate(dir)
+		}
+	}()
+}
+
+// watchRepo adds the checkout at dir to the watcher, if there is one, and
+// validates it in the background.
+func (h *Handler) watchRepo(dir string) {
+	if h.watcher == nil || dir == "" {
+		return
+	}
+	if err := h.watcher.Add(dir); err != nil {
+		log.Printf("not watching %s: %v", dir, err)
+		return
+	}
+	go h.Revalidate(dir)
+}
+
+// Revalidate checks the evidence citing files in the checkout at dir and
+// records each node's status. A caching checker is told that the
+// checkout's refs may have moved. The nodes are copied under the store's lock
+// and checked without it, so git is never run while holding it. Returns the
+// number of nodes whose status changed.
+func (h *Handler) Revalidate(dir string) int {
+	if cache, ok := h.checker.(graph.ValidityCache); ok {
+		cache.RefsChanged(dir)
+	}
+	snapshot := graph.New()
+	var evidence []*graph.EvidenceNode
+	h.store.ReadGraph(func(g *graph.Graph) {
+		for _, ev := range g.EvidenceUnder(dir) {
+			c := *ev
+			snapshot.Evidence[c.ID] = &c
+			evidence = append(evidence, &c)
+		}
+	})
+	if len(evidence) == 0 {
+		return 0
+	}
+	var head string
+	if resolver, ok := graph.Capability[graph.HeadResolver](h.checker); ok {
+		head, _ = resolver.HeadCommit(evidence[0].FilePath)
+	}
+	statuses := make(map[string]string, len(evidence))
+	for _, ev := range evidence {
+		v, err := snapshot.EvidenceValidity(ev.ID, h.checker, graph.CheckOptions{})
+		if err != nil {
+			continue
+		}
+		statuses[ev.ID] = v.Status
+	}
+
+	changed := 0
+	now := time.Now()
+	h.store.WithGraph(func(g *graph.Graph) {
+		for id, status := range statuses {
+			// Evidence deleted while checking is skipped.
+			if ok, err := g.RecordCheck(id, status, head, now); err == nil && ok {
+				changed++
+			}
+		}
+	})
+	if err := h.store.Save(); err != nil {
+		log.Printf("saving statuses checked in %s: %v", dir, err)
+	}
+	return changed
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"trees/graph"
+	"trees/watch"
+)
+
+func TestRevalidate(t *testing.T) {
+	checker := &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			files: map[string]string{"abc123:/home/user/project/f.go": "package f\n"},
+		},
+		head: "def456",
+	}
+	h := newTestHandlerWithChecker(t, checker)
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	ev := h.store.Graph().EvidenceUnder("/home/user/project")[0]
+
+	if changed := h.Revalidate("/home/user/project"); changed != 1 {
+		t.Errorf("expected 1 status change, got %d", changed)
+	}
+	if ev.LastCheck == nil || ev.LastCheck.Status != graph.EvidenceValid || ev.LastCheck.Commit != "def456" {
+		t.Fatalf("expected valid at def456, got %+v", ev.LastCheck)
+	}
+	first := ev.LastCheck.ChangedAt
+
+	if changed := h.Revalidate("/home/user/project"); changed != 0 {
+		t.Errorf("expected no status change, got %d", changed)
+	}
+	checker.changed = true
+	if changed := h.Revalidate("/home/user/project"); changed != 1 {
+		t.Errorf("expected 1 status change, got %d", changed)
+	}
+	if ev.LastCheck.Status != graph.EvidenceStale || !ev.LastCheck.ChangedAt.After(first) {
+		t.Errorf("expected a later transition to stale, got %+v", ev.LastCheck)
+	}
+	if changed := h.Revalidate("/home/user/elsewhere"); changed != 0 {
+		t.Errorf("expected nothing checked outside the checkout, got %d", changed)
+	}
+}
+
+func TestRevalidateRefreshesCachedHead(t *testing.T) {
+	checker := &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			files: map[string]string{"abc123:/home/user/project/f.go": "package f\n"},
+		},
+		head: "def456",
+	}
+	h := newTestHandlerWithChecker(t, graph.NewCachingChecker(checker))
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	get := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		h.Mux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/evidence/"+ev["id"].(string), nil))
+		var got map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&got)
+		return got
+	}
+	if got := get(); got["valid"] != true {
+		t.Fatalf("expected valid evidence, got %v", got)
+	}
+
+	// A commit changing the file moves HEAD, and the watcher sees it.
+	checker.head, checker.changed = "fff000", true
+	h.Revalidate("/home/user/project")
+	if got := get(); got["valid"] != false {
+		t.Errorf("expected stale evidence once HEAD moved, got %v", got)
+	}
+}
+
+// TestRevalidateWhileAddingEvidence is meant for go test -race: evidence
+// added while a checkout is revalidated must not race with the check.
+func TestRevalidateWhileAddingEvidence(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{})
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			body := fmt.Sprintf(`{"file_path": "/home/user/project/f%d.go", "line_ref": "1", "git_commit": "abc123"}`, i)
+			req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(body))
+			h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		h.Revalidate("/home/user/project")
+	}
+	<-done
+
+	h.Revalidate("/home/user/project")
+	for _, ev := range h.store.Graph().EvidenceUnder("/home/user/project") {
+		if ev.LastCheck == nil || ev.LastCheck.Status != graph.EvidenceValid {
+			t.Errorf("expected %s checked valid, got %+v", ev.ID, ev.LastCheck)
+		}
+	}
+}
+
+// TestReadsWhileRevalidating is meant for go test -race: reading claims and
+// evidence while a checkout is revalidated must not race with recording the
+// checks.
+func TestReadsWhileRevalidating(t *testing.T) {
+	h := newTestHandlerWithChecker(t, &mockGitChecker{})
+	req := httptest.NewRequest(http.MethodPost, "/claims", strings.NewReader(`{"content": "c"}`))
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var claim map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&claim)
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	var ev map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&ev)
+	req = httptest.NewRequest(http.MethodPost, "/claims/"+claim["id"].(string)+"/evidence", strings.NewReader(`{"evidence_id": "`+ev["id"].(string)+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			h.Revalidate("/home/user/project")
+		}
+	}()
+	paths := []string{"/claims", "/claims/" + claim["id"].(string), "/evidence", "/evidence/" + ev["id"].(string), "/evidence/" + ev["id"].(string) + "/timeline", "/validate"}
+	for i := 0; i < 20; i++ {
+		for _, path := range paths {
+			w := httptest.NewRecorder()
+			h.Mux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s: expected status 200, got %d", path, w.Code)
+			}
+		}
+	}
+	<-done
+}
+
+func TestWatchRevalidatesOnRefChange(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git", "refs", "heads"), 0755)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	file := filepath.Join(dir, "f.go")
+
+	checker := &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			files: map[string]string{"abc123:" + file: "package f\n"},
+		},
+		head: "def456",
+	}
+	h := newTestHandlerWithChecker(t, checker)
+	req := httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"id": "root1", "path": "`+dir+`"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "`+file+`", "line_ref": "1", "git_commit": "abc123"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	ev := h.store.Graph().EvidenceUnder(dir)[0]
+
+	w, err := watch.New(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Close()
+	h.Watch(w)
+	waitForStatus(t, h, ev, graph.EvidenceValid)
+
+	h.store.WithGraph(func(g *graph.Graph) { checker.changed = true })
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/other\n"), 0644)
+	waitForStatus(t, h, ev, graph.EvidenceStale)
+}
+
+// waitForStatus waits for the background check to record status on ev.
+func waitForStatus(t *testing.T, h *Handler, ev *graph.EvidenceNode, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var got string
+		h.store.WithGraph(func(g *graph.Graph) {
+			if ev.LastCheck != nil {
+				got = ev.LastCheck.Status
+			}
+		})
+		if got == status {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected evidence to be recorded as %s", status)
+}
+
+func TestEvidenceTimeline(t *testing.T) {
+	checker := &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			files: map[string]string{"abc123:/home/user/project/f.go": "package f\n"},
+		},
+		head: "def456",
+	}
+	h := newTestHandlerWithChecker(t, checker)
+
+	req := httptest.NewRequest(http.MethodPost, "/evidence", strings.NewReader(`{"file_path": "/home/user/project/f.go", "line_ref": "1", "git_commit": "abc123"}`))
+	h.Mux().ServeHTTP(httptest.NewRecorder(), req)
+	id := h.store.Graph().EvidenceUnder("/home/user/project")[0].ID
+
+	h.Revalidate("/home/user/project")
+	checker.changed = true
+	h.Revalidate("/home/user/project")
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/"+id+"/timeline", nil)
+	w := httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var timeline []graph.TimelineEvent
+	json.NewDecoder(w.Body).Decode(&timeline)
+	if len(timeline) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(timeline))
+	}
+	if timeline[0].Kind != graph.EventCreated || timeline[0].Commit != "abc123" {
+		t.Errorf("expected creation at abc123, got %+v", timeline[0])
+	}
+	if e := timeline[2]; e.Status != graph.EvidenceStale || e.PrevStatus != graph.EvidenceValid || e.Commit != "def456" {
+		t.Errorf("expected valid -> stale at def456, got %+v", e)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/evidence/nonexistent/timeline", nil)
+	w = httptest.NewRecorder()
+	h.Mux().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hookNames are the git hooks install-hooks sets up.
+var hookNames = []string{"post-merge", "post-checkout", "pre-push"}
+
+// hookMarker identifies hook scripts written by install-hooks, so they can
+// be replaced without --force.
+const hookMarker = "# Installed by trees-cli install-hooks."
+
+// hookScript returns a hook that runs "trees-cli hook <name>" with the
+// hook's arguments and input. It prefers the binary that installed it and
+// falls back to trees-cli on the PATH. It always exits 0, so a missing
+// binary or an unreachable server never blocks git.
+func hookScript(name, cli string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s
+# Warns about claims whose evidence the changed files no longer support.
+cli=%s
+[ -x "$cli" ] || cli=trees-cli
+command -v "$cli" >/dev/null 2>&1 || exit 0
+"$cli" hook %s "$@"
+exit 0
+`, hookMarker, shellQuote(cli), name)
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func installHooks(args []string) error {
+	dir := "."
+	if rest := positionalArgs(args); len(rest) > 0 && !strings.HasPrefix(rest[0], "--") {
+		dir = rest[0]
+	}
+	force := hasFlag(args, "--force")
+
+	hooksDir, err := gitOutput(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return fmt.Errorf("finding hooks directory: %v (is %s in a git repo?)", err, dir)
+	}
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return err
+	}
+	cli, err := os.Executable()
+	if err != nil {
+		cli = "trees-cli"
+	}
+
+	skipped := 0
+	for _, name := range hookNames {
+		path := filepath.Join(hooksDir, name)
+		if existing, err := os.ReadFile(path); err == nil && !force && !strings.Contains(string(existing), hookMarker) {
+			fmt.Printf("Skipped %s: %s already exists (use --force to replace it)\n", name, path)
+			skipped++
+			continue
+		}
+		if err := os.WriteFile(path, []byte(hookScript(name, cli)), 0755); err != nil {
+			return err
+		}
+		fmt.Printf("Installed %s hook: %s\n", name, path)
+	}
+	if skipped > 0 {
+		fmt.Printf("To keep an existing hook, add this line to it: %s hook <name> \"$@\"\n", cli)
+	}
+	return nil
+}
+
+// hookTimeout bounds each request a hook makes, so a server that accepts
+// the connection and then hangs holds git up only briefly.
+const hookTimeout = 3 * time.Second
+
+// runHook is called by the installed git hooks. It validates the claims
+// citing files changed by the merge, checkout or push that triggered it and
+// prints those that are no longer valid. If the server does not answer
+// within hookTimeout, the warnings are dropped.
+func runHook(client *Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: hook <post-merge|post-checkout|pre-push> [hook args]")
+	}
+	name, args := args[0], args[1:]
+	top, err := gitOutput(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	timed := *client
+	timed.http = &http.Client{Timeout: hookTimeout}
+	err = hook(&timed, top, name, args)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	return err
+}
+
+// hook runs the hook called name in the checkout at top.
+func hook(client *Client, top, name string, args []string) error {
+	switch name {
+	case "post-merge":
+		files, err := changedFiles(top, "ORIG_HEAD", "HEAD")
+		if err != nil {
+			return err
+		}
+		return warnInvalidated(client, top, files, "", "this merge")
+	case "post-checkout":
+		// Arguments are the previous HEAD, the new HEAD, and 1 for a
+		// branch checkout or 0 for a file checkout.
+		if len(args) < 3 || args[2] != "1" || args[0] == args[1] {
+			return nil
+		}
+		files, err := changedFiles(top, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		return warnInvalidated(client, top, files, "", "this checkout")
+	case "pre-push":
+		return prePush(client, top, os.Stdin)
+	default:
+		return fmt.Errorf("unknown hook: %s", name)
+	}
+}
+
+// prePush reads the refs being pushed, one "<local ref> <local commit>
+// <remote ref> <remote commit>" line each, and checks the claims citing
+// files the outgoing commits change against the commit being pushed.
+func prePush(client *Client, top string, input io.Reader) error {
+	head, _ := gitOutput(top, "rev-parse", "HEAD")
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// A missing ref is given as an all-zero object name.
+		if len(fields) < 4 || strings.Trim(fields[1], "0") == "" {
+			continue // deleting a remote ref pushes no commits
+		}
+		localRef, local, remote := fields[0], fields[1], fields[3]
+
+		var files []string
+		var err error
+		if strings.Trim(remote, "0") != "" {
+			files, err = changedFiles(top, remote, local)
+		}
+		if strings.Trim(remote, "0") == "" || err != nil {
+			// A new branch, or a remote commit we have not fetched: take
+			// the commits no remote has seen.
+			var out string
+			out, err = gitOutput(top, "log", "--format=", "--name-only", "--no-renames", local, "--not", "--remotes")
+			files = splitLines(out)
+		}
+		if err != nil {
+			return err
+		}
+		ref := ""
+		if local != head {
+			ref = local
+		}
+		if err := warnInvalidated(client, top, files, ref, "pushing "+localRef); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// changedFiles lists the files that differ between two commits, by path
+// relative to the top of the checkout. A renamed file is listed under both
+// its old and new path.
+func changedFiles(top, from, to string) ([]string, error) {
+	out, err := gitOutput(top, "diff", "--name-only", "--no-renames", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(out), nil
+}
+
+// warnInvalidated validates the claims citing files, relative to top, at ref
+// (or HEAD) and prints those that are invalid.
+func warnInvalidated(client *Client, top string, files []string, ref, what string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	q := url.Values{}
+	seen := map[string]bool{}
+	for _, f := range files {
+		if !seen[f] {
+			seen[f] = true
+			q.Add("path", filepath.Join(top, f))
+		}
+	}
+	q.Set("ref", ref)
+	report, err := fetchValidation(client, q)
+	if err != nil {
+		return err
+	}
+	if report.Invalid == 0 {
+		return nil
+	}
+
+	fmt.Printf("trees: %d claim(s) citing files changed by %s are no longer valid:\n", report.Invalid, what)
+	for _, c := range report.Claims {
+		if !c.Valid {
+			printClaimValidation(c, "  ")
+		}
+	}
+	fmt.Println("trees: re-point the evidence with reanchor-evidence or post-evidence, or review the claims.")
+	return nil
+}
+
+// gitOutput runs git in dir and returns its output without surrounding
+// whitespace.
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := gitRun(dir, nil, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitRun runs git in dir with stdin as its input and returns its output. A
+// failure is reported with what git wrote to stderr.
+func gitRun(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// impact lists the claims whose evidence a change touches: the commits in a
+// range, or a patch read from stdin.
+func impact(client *Client, args []string) error {
+	root := parseFlag(args, "--root")
+	if root == "" {
+		root = "."
+	}
+	top, err := gitOutput(root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return fmt.Errorf("finding the checkout: %v (is %s in a git repo?)", err, root)
+	}
+
+	var patch []byte
+	base := parseFlag(args, "--base")
+	rest := positionalArgs(args, "--root", "--base")
+	if len(rest) > 0 && rest[0] != "-" {
+		rangeArg := rest[0]
+		if base, err = rangeBase(top, rangeArg); err != nil {
+			return err
+		}
+		out, err := gitOutput(top, "diff", "--no-color", "--no-ext-diff", "-U0", rangeArg)
+		if err != nil {
+			return err
+		}
+		patch = []byte(out + "\n")
+	} else {
+		if patch, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("reading patch: %v", err)
+		}
+	}
+
+	result, err := client.post("/impact", map[string]string{
+		"patch": string(patch),
+		"root":  top,
+		"base":  base,
+	})
+	if err != nil {
+		return err
+	}
+
+	claims := asList(result["claims"])
+	for _, e := range asList(result["unchecked"]) {
+		ev := e.(map[string]interface{})
+		fmt.Fprintf(os.Stderr, "warning: could not check %s (%s %s): %v\n", ev["id"], ev["file_path"], ev["line_ref"], ev["error"])
+	}
+	if len(claims) == 0 {
+		fmt.Println("This change affects no claims.")
+		return nil
+	}
+	fmt.Printf("This change affects %d claim(s) through %v evidence node(s):\n", len(claims), result["evidence"])
+	for _, c := range claims {
+		claim := c.(map[string]interface{})
+		fmt.Printf("%s  (%s)  %s\n", claim["id"], claim["status"], claim["content"])
+		for _, e := range asList(claim["evidence"]) {
+			ev := e.(map[string]interface{})
+			path, lines := ev["file_path"], ev["line_ref"]
+			if p, ok := ev["base_path"].(string); ok && p != "" {
+				path = p
+			}
+			if l, ok := ev["base_line_ref"].(string); ok && l != "" {
+				lines = l
+			}
+			var hunks []string
+			for _, h := range asList(ev["hunks"]) {
+				hunks = append(hunks, fmt.Sprint(h))
+			}
+			fmt.Printf("    %s: %s  %s  %s  changed by %s\n", ev["kind"], ev["id"], path, lines, strings.Join(hunks, ", "))
+		}
+	}
+	return nil
+}
+
+// rangeBase returns the commit a git diff of rangeArg compares against: the
+// left side of "base..head", or the merge base of "base...head". A missing
+// side means HEAD, and a single commit is compared with the working tree.
+func rangeBase(top, rangeArg string) (string, error) {
+	if left, right, ok := strings.Cut(rangeArg, "..."); ok {
+		return gitOutput(top, "merge-base", orHead(left), orHead(right))
+	}
+	left, _, _ := strings.Cut(rangeArg, "..")
+	return gitOutput(top, "rev-parse", "--verify", orHead(left)+"^{commit}")
+}
+
+func orHead(rev string) string {
+	if rev == "" {
+		return "HEAD"
+	}
+	return rev
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -23,7 +24,16 @@ func main() {
 		baseURL = "http://localhost:8080"
 	}
 
-	client := &Client{baseURL: baseURL, http: &http.Client{}}
+	client := &Client{
+		baseURL: baseURL,
+		http:    &http.Client{},
+		headers: map[string]string{
+			"X-Trees-Author":  os.Getenv("TREES_AUTHOR"),
+			"X-Trees-Session": os.Getenv("TREES_SESSION"),
+			"X-Trees-Source":  os.Getenv("TREES_SOURCE"),
+			"X-Trees-Tool":    "trees-cli",
+		},
+	}
 
 	switch os.Args[1] {
 	case "post-evidence":
@@ -41,8 +51,13 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "review-claim":
+		if err := reviewClaim(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "list-claims":
-		if err := listClaims(client); err != nil {
+		if err := listClaims(client, os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
@@ -51,6 +66,26 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "blame-claim":
+		if err := blameClaim(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "reanchor-evidence":
+		if err := reanchorEvidence(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "evidence-timeline":
+		if err := evidenceTimeline(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "delete-evidence":
+		if err := deleteEvidence(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "list-evidence":
 		if err := listEvidence(client); err != nil {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -61,6 +96,46 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "add-repo":
+		if err := addRepo(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "list-repos":
+		if err := listRepos(client); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "validate":
+		if err := validate(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "impact":
+		if err := impact(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "push":
+		if err := push(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "pull":
+		if err := pull(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "install-hooks":
+		if err := installHooks(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "hook":
+		if err := runHook(client, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "trees: %v\n", err)
+			os.Exit(1)
+		}
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
 		printUsage()
@@ -72,37 +147,135 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, `Usage: trees-cli <command> [args]
 
 Commands:
-  post-evidence --file <path> --lines <ref> [--claim <id>]
+  post-evidence --file <path> (--lines <ref> | --symbol <pkg.Name>) [--claim <id> [--kind <kind>]]
       Post a file reference as evidence. Path is resolved to absolute.
       Git commit is auto-detected from the file's repository HEAD.
+      A Go symbol (Func, Type or Type.Method) may be cited instead of
+      lines; it stays valid while the symbol's source is unchanged.
       Optionally link to an existing claim.
 
-  create-claim <content>
-      Create a new claim node.
+  create-claim [--tag <tag>]... <content>
+      Create a new claim node, optionally tagged for grouping.
+
+  link-evidence --claim <id> --evidence <id> [--kind <kind>]
+      Link an existing evidence node to a claim. The kind is supports
+      (default), refutes or context.
+
+  review-claim <id> --status <status> [--comment <text>] [--reviewer <name>]
+      Move a claim to draft, verified, disputed or retracted. The
+      reviewer defaults to TREES_AUTHOR.
 
-  link-evidence --claim <id> --evidence <id>
-      Link an existing evidence node to a claim.
+  list-claims [--tag <tag>]... [--status <status>] [--author <name>] [--session <id>]
+      List all claims, or only those carrying every given tag, in the
+      given review status, and created by the given author or session.
 
-  list-claims
-      List all claims.
+  show-claim <id> [--snippets] [--normalize] [--working-tree] [--ref <ref> | --at <commit>]
+      Show a claim and its linked evidence. With --snippets, also print
+      the cited lines at the recorded commit and at HEAD.
 
-  show-claim <id>
-      Show a claim and its linked evidence.
+  blame-claim <id> [--ref <ref> | --at <commit>]
+      For each piece of the claim's evidence that is no longer valid, show
+      the first commit after its recorded one that changed the cited
+      lines, with its author and message.
 
   list-evidence
       List all evidence nodes.
 
-  show-evidence <id>
-      Show an evidence node.
+  show-evidence <id> [--snippets] [--normalize] [--working-tree] [--ref <ref> | --at <commit>]
+      Show an evidence node, optionally with its cited lines. The status
+      the server last recorded while watching the repository is shown
+      along with when it last changed.
+
+  reanchor-evidence <id> [--normalize] [--ref <ref>]
+      Move still-valid evidence to where it lives at HEAD (or the given
+      ref): its current path after a rename, its current lines, and the
+      commit.
+
+  evidence-timeline <id>
+      Show when an evidence node was created, each change of status the
+      server recorded while watching its repository, and re-anchors.
+
+  delete-evidence <id>
+      Delete an evidence node and unlink it from its claims.
+
+  validate [--tag <tag>]... [--path <path>]... [--normalize] [--working-tree] [--ref <ref> | --at <commit>]
+      Check the evidence of every claim, or only claims carrying every
+      given tag and citing one of the given files or a file inside one of
+      the given directories. Evidence citing deleted files is listed
+      separately. Exits non-zero if any claim has invalid evidence.
+
+  impact [<base>..<head> | <base>...<head> | <commit> | -] [--root <dir>] [--base <commit>]
+      List the claims whose evidence cites lines a change touches, before
+      it is committed or merged. The change is the git diff of the given
+      range or commit, or a unified diff read from stdin (with no range or
+      -) that applies to --base, or HEAD. The checkout is the one at
+      --root, or the current directory.
+
+  add-repo [<path>] [--default-branch <ref>]
+      Register the repository checked out at path (default: the current
+      directory). The default branch is detected unless given.
+      Repositories are also registered when evidence is posted from them.
+
+  list-repos
+      List registered repositories.
+
+  pull [<remote>]
+      Fetch the graph others pushed to the remote (default: origin) and
+      merge it into the server's. Run it inside the repository. Merging
+      never removes nodes, combines reviews and re-anchors, and settles
+      conflicts the same way on every machine.
+
+  push [<remote>]
+      Pull, then commit the server's graph to refs/trees/graph, one file
+      per claim, evidence node and link, and push it to the remote
+      (default: origin).
+
+  install-hooks [<path>] [--force]
+      Install post-merge, post-checkout and pre-push hooks in the
+      repository at path (default: the current directory). After a merge
+      or branch checkout, and before a push, they print the claims citing
+      changed files that are no longer valid. Existing hooks are left
+      alone unless --force is given. The hooks never stop git.
+
+  hook <post-merge|post-checkout|pre-push> [hook args]
+      Run the check for one hook; called by the installed hooks.
+
+  With --normalize, validity ignores changes that only reformat, re-comment
+  or shift the cited code. With --working-tree, still-valid evidence whose
+  cited lines have uncommitted edits is reported as DIRTY. With --ref,
+  validity is checked against a branch, tag or commit instead of HEAD.
+  --at does the same as of a point in history, such as a release tag:
+  evidence recorded at commits it does not contain is NOT_IN_HISTORY.
 
 Environment:
-  TREES_URL    Server URL (default: http://localhost:8080)
+  TREES_URL      Server URL (default: http://localhost:8080)
+  TREES_AUTHOR   Author recorded on created nodes (a person or agent name)
+  TREES_SESSION  Session recorded on created nodes (e.g. an agent run ID)
+  TREES_SOURCE   Free-form note on where the research came from
 `)
 }
 
 type Client struct {
 	baseURL string
 	http    *http.Client
+	headers map[string]string
+}
+
+// do sends a request carrying the client's provenance headers.
+func (c *Client) do(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.headers {
+		if v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+	return c.http.Do(req)
 }
 
 func (c *Client) post(path string, body interface{}) (map[string]interface{}, error) {
@@ -110,7 +283,7 @@ func (c *Client) post(path string, body interface{}) (map[string]interface{}, er
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.http.Post(c.baseURL+path, "application/json", bytes.NewReader(data))
+	resp, err := c.do(http.MethodPost, path, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +292,7 @@ func (c *Client) post(path string, body interface{}) (map[string]interface{}, er
 }
 
 func (c *Client) get(path string) ([]byte, error) {
-	resp, err := c.http.Get(c.baseURL + path)
+	resp, err := c.do(http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -158,6 +331,72 @@ func parseFlag(args []string, flag string) string {
 	return ""
 }
 
+func parseFlagValues(args []string, flag string) []string {
+	var values []string
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			values = append(values, args[i+1])
+		}
+	}
+	return values
+}
+
+// positionalArgs returns args with the given flags and their values removed.
+func positionalArgs(args []string, flags ...string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		isFlag := false
+		for _, f := range flags {
+			if args[i] == f {
+				isFlag = true
+				break
+			}
+		}
+		if isFlag {
+			i++
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return result
+}
+
+// encodeQuery encodes the non-empty values as a query string, including the
+// leading "?", or returns "" if there are none.
+func encodeQuery(q url.Values) string {
+	for k, v := range q {
+		if len(v) == 0 || (len(v) == 1 && v[0] == "") {
+			delete(q, k)
+		}
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
+
+// formatTally renders a decoded evidence tally as "valid/total valid".
+func formatTally(v interface{}) string {
+	t, _ := v.(map[string]interface{})
+	return fmt.Sprintf("%v/%v valid", t["valid"], t["total"])
+}
+
+// formatProvenance renders a decoded JSON provenance object on one line, or
+// returns "" if it is absent.
+func formatProvenance(v interface{}) string {
+	p, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	var parts []string
+	for _, key := range []string{"author", "session", "tool", "source"} {
+		if val, ok := p[key].(string); ok && val != "" {
+			parts = append(parts, key+"="+val)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 func gitHeadCommit(dir string) (string, error) {
 	cmd := exec.Command("git", "rev-parse", "HEAD")
 	cmd.Dir = dir
@@ -171,10 +410,12 @@ func gitHeadCommit(dir string) (string, error) {
 func postEvidence(client *Client, args []string) error {
 	filePath := parseFlag(args, "--file")
 	lineRef := parseFlag(args, "--lines")
+	symbol := parseFlag(args, "--symbol")
 	claimID := parseFlag(args, "--claim")
+	kind := parseFlag(args, "--kind")
 
-	if filePath == "" || lineRef == "" {
-		return fmt.Errorf("usage: post-evidence --file <path> --lines <ref> [--claim <id>]")
+	if filePath == "" || (lineRef == "") == (symbol == "") {
+		return fmt.Errorf("usage: post-evidence --file <path> (--lines <ref> | --symbol <pkg.Name>) [--claim <id> [--kind <kind>]]")
 	}
 
 	// Resolve to absolute path
@@ -195,6 +436,7 @@ func postEvidence(client *Client, args []string) error {
 	result, err := client.post("/evidence", map[string]string{
 		"file_path":  filePath,
 		"line_ref":   lineRef,
+		"symbol":     symbol,
 		"git_commit": gitCommit,
 	})
 	if err != nil {
@@ -205,12 +447,16 @@ func postEvidence(client *Client, args []string) error {
 	fmt.Printf("Created evidence %s\n", evID)
 	fmt.Printf("  file: %s\n", result["file_path"])
 	fmt.Printf("  lines: %s\n", result["line_ref"])
+	if symbol != "" {
+		fmt.Printf("  symbol: %s\n", result["symbol"])
+	}
 	fmt.Printf("  commit: %s\n", result["git_commit"])
 
 	// Optionally link to claim
 	if claimID != "" {
 		_, err := client.post("/claims/"+claimID+"/evidence", map[string]string{
 			"evidence_id": evID,
+			"kind":        kind,
 		})
 		if err != nil {
 			return fmt.Errorf("linking to claim: %v", err)
@@ -222,13 +468,16 @@ func postEvidence(client *Client, args []string) error {
 }
 
 func createClaim(client *Client, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: create-claim <content>")
+	tags := parseFlagValues(args, "--tag")
+	words := positionalArgs(args, "--tag")
+	if len(words) == 0 {
+		return fmt.Errorf("usage: create-claim [--tag <tag>]... <content>")
 	}
-	content := strings.Join(args, " ")
+	content := strings.Join(words, " ")
 
-	result, err := client.post("/claims", map[string]string{
+	result, err := client.post("/claims", map[string]interface{}{
 		"content": content,
+		"tags":    tags,
 	})
 	if err != nil {
 		return err
@@ -236,6 +485,40 @@ func createClaim(client *Client, args []string) error {
 
 	fmt.Printf("Created claim %s\n", result["id"])
 	fmt.Printf("  content: %s\n", result["content"])
+	if tags := formatTags(result["tags"]); tags != "" {
+		fmt.Printf("  tags: %s\n", tags)
+	}
+	return nil
+}
+
+// formatTags renders a decoded JSON tag list as a comma-separated string.
+func formatTags(v interface{}) string {
+	list, _ := v.([]interface{})
+	tags := make([]string, 0, len(list))
+	for _, t := range list {
+		tags = append(tags, fmt.Sprint(t))
+	}
+	return strings.Join(tags, ", ")
+}
+
+func reviewClaim(client *Client, args []string) error {
+	status := parseFlag(args, "--status")
+	rest := positionalArgs(args, "--status", "--comment", "--reviewer")
+	if len(rest) == 0 || status == "" {
+		return fmt.Errorf("usage: review-claim <id> --status <status> [--comment <text>] [--reviewer <name>]")
+	}
+	claimID := rest[0]
+
+	result, err := client.post("/claims/"+claimID+"/review", map[string]string{
+		"status":   status,
+		"comment":  parseFlag(args, "--comment"),
+		"reviewer": parseFlag(args, "--reviewer"),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Claim %s is now %s\n", claimID, result["status"])
 	return nil
 }
 
@@ -244,11 +527,12 @@ func linkEvidence(client *Client, args []string) error {
 	evidenceID := parseFlag(args, "--evidence")
 
 	if claimID == "" || evidenceID == "" {
-		return fmt.Errorf("usage: link-evidence --claim <id> --evidence <id>")
+		return fmt.Errorf("usage: link-evidence --claim <id> --evidence <id> [--kind <kind>]")
 	}
 
 	_, err := client.post("/claims/"+claimID+"/evidence", map[string]string{
 		"evidence_id": evidenceID,
+		"kind":        parseFlag(args, "--kind"),
 	})
 	if err != nil {
 		return err
@@ -258,8 +542,13 @@ func linkEvidence(client *Client, args []string) error {
 	return nil
 }
 
-func listClaims(client *Client) error {
-	body, err := client.get("/claims")
+func listClaims(client *Client, args []string) error {
+	body, err := client.get("/claims" + encodeQuery(url.Values{
+		"tag":     parseFlagValues(args, "--tag"),
+		"status":  {parseFlag(args, "--status")},
+		"author":  {parseFlag(args, "--author")},
+		"session": {parseFlag(args, "--session")},
+	}))
 	if err != nil {
 		return err
 	}
@@ -275,17 +564,22 @@ func listClaims(client *Client) error {
 	}
 
 	for _, c := range claims {
-		fmt.Printf("%s  %s\n", c["id"], c["content"])
+		if tags := formatTags(c["tags"]); tags != "" {
+			fmt.Printf("%s  (%s)  %s  [%s]\n", c["id"], c["status"], c["content"], tags)
+		} else {
+			fmt.Printf("%s  (%s)  %s\n", c["id"], c["status"], c["content"])
+		}
 	}
 	return nil
 }
 
 func showClaim(client *Client, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: show-claim <id>")
+	id := firstArg(args)
+	if id == "" {
+		return fmt.Errorf("usage: show-claim <id> [--snippets]")
 	}
 
-	body, err := client.get("/claims/" + args[0])
+	body, err := client.get("/claims/" + id + viewQuery(args))
 	if err != nil {
 		return err
 	}
@@ -297,17 +591,55 @@ func showClaim(client *Client, args []string) error {
 
 	fmt.Printf("Claim: %s\n", claim["id"])
 	fmt.Printf("  content: %s\n", claim["content"])
+	fmt.Printf("  status: %s\n", claim["status"])
 	fmt.Printf("  created: %s\n", claim["created_at"])
+	if tags := formatTags(claim["tags"]); tags != "" {
+		fmt.Printf("  tags: %s\n", tags)
+	}
+	if prov := formatProvenance(claim["provenance"]); prov != "" {
+		fmt.Printf("  provenance: %s\n", prov)
+	}
+	if reviews, ok := claim["reviews"].([]interface{}); ok && len(reviews) > 0 {
+		fmt.Printf("  reviews (%d):\n", len(reviews))
+		for _, r := range reviews {
+			rv := r.(map[string]interface{})
+			fmt.Printf("    %s  %s by %s", rv["created_at"], rv["status"], rv["reviewer"])
+			if comment, ok := rv["comment"].(string); ok && comment != "" {
+				fmt.Printf(": %s", comment)
+			}
+			fmt.Println()
+		}
+	}
 
 	if evidence, ok := claim["evidence"].([]interface{}); ok && len(evidence) > 0 {
 		fmt.Printf("  evidence (%d):\n", len(evidence))
+		if summary, ok := claim["summary"].(map[string]interface{}); ok {
+			fmt.Printf("    %s supporting, %s refuting, %s context\n",
+				formatTally(summary["supports"]), formatTally(summary["refutes"]), formatTally(summary["context"]))
+		}
 		for _, e := range evidence {
 			ev := e.(map[string]interface{})
-			status := "VALID"
-			if valid, ok := ev["valid"].(bool); ok && !valid {
-				status = "INVALID"
+			status := evidenceStatus(ev)
+			fmt.Printf("    [%s] %s %s  %s  %s  @%s\n", status, ev["kind"], ev["id"], ev["file_path"], ev["line_ref"], ev["git_commit"])
+			if symbol, ok := ev["symbol"].(string); ok && symbol != "" {
+				fmt.Printf("        symbol: %s\n", symbol)
+			}
+			if ref := formatRef(ev); ref != "" {
+				fmt.Printf("        checked against: %s\n", ref)
+			}
+			if current, ok := ev["current_path"].(string); ok && current != "" {
+				fmt.Printf("        moved to: %s (reanchor-evidence %s to update)\n", current, ev["id"])
 			}
-			fmt.Printf("    [%s] %s  %s  %s  @%s\n", status, ev["id"], ev["file_path"], ev["line_ref"], ev["git_commit"])
+			if current, ok := ev["current_line_ref"].(string); ok && current != "" {
+				fmt.Printf("        now at lines: %s\n", current)
+			}
+			if prov := formatProvenance(ev["provenance"]); prov != "" {
+				fmt.Printf("        provenance: %s\n", prov)
+			}
+			if prov := formatProvenance(ev["link_provenance"]); prov != "" {
+				fmt.Printf("        linked by: %s\n", prov)
+			}
+			printSnippet(ev["snippet"], "        ")
 		}
 	} else {
 		fmt.Println("  evidence: (none)")
@@ -315,6 +647,172 @@ func showClaim(client *Client, args []string) error {
 	return nil
 }
 
+func blameClaim(client *Client, args []string) error {
+	id := firstArg(args)
+	if id == "" {
+		return fmt.Errorf("usage: blame-claim <id> [--ref <ref> | --at <commit>]")
+	}
+
+	body, err := client.get("/claims/" + id + "/blame" + encodeQuery(checkQuery(args)))
+	if err != nil {
+		return err
+	}
+
+	var claim map[string]interface{}
+	if err := json.Unmarshal(body, &claim); err != nil {
+		return err
+	}
+
+	fmt.Printf("Claim: %s\n", claim["id"])
+	fmt.Printf("  content: %s\n", claim["content"])
+	evidence := asList(claim["evidence"])
+	if len(evidence) == 0 {
+		fmt.Println("  all evidence is still valid")
+		return nil
+	}
+	for _, e := range evidence {
+		ev := e.(map[string]interface{})
+		fmt.Printf("  [%s] %s  %s  %s  @%s\n", evidenceStatus(ev), ev["id"], ev["file_path"], ev["line_ref"], ev["git_commit"])
+		if msg, ok := ev["error"].(string); ok && msg != "" {
+			fmt.Printf("      cannot tell: %s\n", msg)
+			continue
+		}
+		change, ok := ev["change"].(map[string]interface{})
+		if !ok {
+			fmt.Println("      cited lines are unchanged; the file changed around them")
+			continue
+		}
+		fmt.Printf("      changed in %s by %s <%s> on %s\n", change["commit"], change["author"], change["email"], change["date"])
+		for _, line := range strings.Split(fmt.Sprint(change["message"]), "\n") {
+			fmt.Println(strings.TrimRight("          "+line, " "))
+		}
+	}
+	return nil
+}
+
+func reanchorEvidence(client *Client, args []string) error {
+	id := firstArg(args)
+	if id == "" {
+		return fmt.Errorf("usage: reanchor-evidence <id> [--normalize] [--ref <ref>]")
+	}
+
+	result, err := client.post("/evidence/"+id+"/reanchor"+encodeQuery(checkQuery(args)), nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-anchored evidence %s\n", result["id"])
+	fmt.Printf("  file: %s\n", result["file_path"])
+	fmt.Printf("  lines: %s\n", result["line_ref"])
+	fmt.Printf("  commit: %s\n", result["git_commit"])
+	return nil
+}
+
+func evidenceTimeline(client *Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: evidence-timeline <id>")
+	}
+
+	body, err := client.get("/evidence/" + args[0] + "/timeline")
+	if err != nil {
+		return err
+	}
+
+	var events []map[string]interface{}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Timeline of evidence %s\n", args[0])
+	for _, e := range events {
+		at := e["at"]
+		switch e["kind"] {
+		case "created":
+			fmt.Printf("  %s  created: %s:%s @%s\n", at, e["file_path"], e["line_ref"], e["commit"])
+		case "reanchored":
+			fmt.Printf("  %s  re-anchored: %s:%s @%s (was %s:%s @%s)\n", at,
+				e["file_path"], e["line_ref"], e["commit"], e["prev_file_path"], e["prev_line_ref"], e["prev_commit"])
+		default:
+			status := strings.ToUpper(fmt.Sprint(e["status"]))
+			if prev, ok := e["prev_status"].(string); ok && prev != "" {
+				status += " (was " + strings.ToUpper(prev) + ")"
+			}
+			fmt.Printf("  %s  %s at %s\n", at, status, e["commit"])
+		}
+	}
+	return nil
+}
+
+func deleteEvidence(client *Client, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: delete-evidence <id>")
+	}
+
+	resp, err := client.do(http.MethodDelete, "/evidence/"+args[0], nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := readJSON(resp); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted evidence %s\n", args[0])
+	return nil
+}
+
+func addRepo(client *Client, args []string) error {
+	path := "."
+	if rest := positionalArgs(args, "--default-branch"); len(rest) > 0 {
+		path = rest[0]
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving path: %w", err)
+	}
+
+	result, err := client.post("/repos", map[string]interface{}{
+		"path":           absPath,
+		"default_branch": parseFlag(args, "--default-branch"),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Registered repository %s\n", result["id"])
+	fmt.Printf("  path: %s\n", result["path"])
+	if branch, ok := result["default_branch"].(string); ok && branch != "" {
+		fmt.Printf("  default branch: %s\n", branch)
+	}
+	return nil
+}
+
+func listRepos(client *Client) error {
+	body, err := client.get("/repos")
+	if err != nil {
+		return err
+	}
+
+	var repos []map[string]interface{}
+	if err := json.Unmarshal(body, &repos); err != nil {
+		return err
+	}
+
+	if len(repos) == 0 {
+		fmt.Println("No repositories.")
+		return nil
+	}
+
+	for _, r := range repos {
+		fmt.Printf("%s  %s", r["id"], r["path"])
+		if branch, ok := r["default_branch"].(string); ok && branch != "" {
+			fmt.Printf("  (%s)", branch)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
 func listEvidence(client *Client) error {
 	body, err := client.get("/evidence")
 	if err != nil {
@@ -338,11 +836,12 @@ func listEvidence(client *Client) error {
 }
 
 func showEvidence(client *Client, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("usage: show-evidence <id>")
+	id := firstArg(args)
+	if id == "" {
+		return fmt.Errorf("usage: show-evidence <id> [--snippets]")
 	}
 
-	body, err := client.get("/evidence/" + args[0])
+	body, err := client.get("/evidence/" + id + viewQuery(args))
 	if err != nil {
 		return err
 	}
@@ -354,15 +853,272 @@ func showEvidence(client *Client, args []string) error {
 
 	fmt.Printf("Evidence: %s\n", ev["id"])
 	fmt.Printf("  file: %s\n", ev["file_path"])
+	if repo, ok := ev["repo"].(string); ok && repo != "" {
+		fmt.Printf("  repo: %s  path: %s\n", repo, ev["path"])
+	}
 	fmt.Printf("  lines: %s\n", ev["line_ref"])
+	if current, ok := ev["current_path"].(string); ok && current != "" {
+		fmt.Printf("  moved to: %s (reanchor-evidence %s to update)\n", current, ev["id"])
+	}
+	if current, ok := ev["current_line_ref"].(string); ok && current != "" {
+		fmt.Printf("  now at lines: %s\n", current)
+	}
+	if symbol, ok := ev["symbol"].(string); ok && symbol != "" {
+		fmt.Printf("  symbol: %s\n", symbol)
+	}
 	fmt.Printf("  commit: %s\n", ev["git_commit"])
-	if valid, ok := ev["valid"].(bool); ok {
-		if valid {
-			fmt.Println("  status: VALID")
-		} else {
-			fmt.Println("  status: INVALID (file changed since commit)")
-		}
+	switch status := evidenceStatus(ev); status {
+	case "VALID":
+		fmt.Println("  status: VALID")
+	case "DELETED":
+		fmt.Printf("  status: DELETED (file deleted in %s)\n", ev["deleted_in"])
+	case "DIRTY":
+		fmt.Println("  status: DIRTY (cited lines have uncommitted edits)")
+	case "NOT_IN_HISTORY":
+		fmt.Println("  status: NOT_IN_HISTORY (recorded at a commit the checked commit does not contain)")
+	default:
+		fmt.Printf("  status: %s (cited code changed since commit)\n", status)
+	}
+	if ref := formatRef(ev); ref != "" {
+		fmt.Printf("  checked against: %s\n", ref)
+	}
+	if check, ok := ev["last_check"].(map[string]interface{}); ok {
+		fmt.Printf("  watched: %s since %s (last checked %s at %s)\n",
+			strings.ToUpper(fmt.Sprint(check["status"])), check["changed_at"], check["checked_at"], check["commit"])
+	}
+	if prov := formatProvenance(ev["provenance"]); prov != "" {
+		fmt.Printf("  provenance: %s\n", prov)
 	}
 	fmt.Printf("  created: %s\n", ev["created_at"])
+	printSnippet(ev["snippet"], "  ")
+	return nil
+}
+
+// firstArg returns the first argument that is neither a flag nor the value
+// of a flag.
+func firstArg(args []string) string {
+	for _, a := range positionalArgs(args, "--ref", "--at") {
+		if !strings.HasPrefix(a, "--") {
+			return a
+		}
+	}
+	return ""
+}
+
+func hasFlag(args []string, flag string) bool {
+	for _, a := range args {
+		if a == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkQuery translates validity flags into query parameters.
+func checkQuery(args []string) url.Values {
+	q := url.Values{}
+	if hasFlag(args, "--normalize") {
+		q.Set("normalize", "true")
+	}
+	if hasFlag(args, "--working-tree") {
+		q.Set("working_tree", "true")
+	}
+	if ref := parseFlag(args, "--ref"); ref != "" {
+		q.Set("ref", ref)
+	}
+	if at := parseFlag(args, "--at"); at != "" {
+		q.Set("at", at)
+	}
+	return q
+}
+
+// viewQuery translates validity and --snippets flags into a query string.
+func viewQuery(args []string) string {
+	q := checkQuery(args)
+	if hasFlag(args, "--snippets") {
+		q.Set("include", "snippet")
+	}
+	return encodeQuery(q)
+}
+
+// printSnippet prints decoded cited lines at the recorded commit and at HEAD,
+// marking HEAD lines that differ from the corresponding commit line with "~".
+func printSnippet(v interface{}, indent string) {
+	snippet, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	var atCommit []string
+	fmt.Printf("%sat commit:\n", indent)
+	for _, l := range asList(snippet["at_commit"]) {
+		line := l.(map[string]interface{})
+		atCommit = append(atCommit, fmt.Sprint(line["text"]))
+		fmt.Printf("%s  %4v   %s\n", indent, line["number"], line["text"])
+	}
+	head := asList(snippet["at_head"])
+	if head == nil {
+		fmt.Printf("%sat HEAD: (file not readable)\n", indent)
+		return
+	}
+	fmt.Printf("%sat HEAD:\n", indent)
+	for i, l := range head {
+		line := l.(map[string]interface{})
+		marker := " "
+		if i >= len(atCommit) || atCommit[i] != line["text"] {
+			marker = "~"
+		}
+		fmt.Printf("%s  %4v %s %s\n", indent, line["number"], marker, line["text"])
+	}
+}
+
+// formatRef describes the ref decoded evidence was checked against, or ""
+// when it was checked against HEAD.
+func formatRef(ev map[string]interface{}) string {
+	ref, _ := ev["ref"].(string)
+	if ref == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", ref, ev["ref_commit"])
+}
+
+// evidenceStatus returns the upper-cased validity status of decoded evidence.
+func evidenceStatus(ev map[string]interface{}) string {
+	if status, ok := ev["status"].(string); ok && status != "" {
+		return strings.ToUpper(status)
+	}
+	if valid, ok := ev["valid"].(bool); ok && !valid {
+		return "INVALID"
+	}
+	return "VALID"
+}
+
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// validationReport is the decoded response of GET /validate.
+type validationReport struct {
+	Claims  []claimValidation `json:"claims"`
+	Valid   int               `json:"valid"`
+	Invalid int               `json:"invalid"`
+	Deleted []struct {
+		ID        string   `json:"id"`
+		FilePath  string   `json:"file_path"`
+		DeletedIn string   `json:"deleted_in"`
+		ClaimIDs  []string `json:"claim_ids"`
+	} `json:"deleted"`
+	Repos []struct {
+		ID      string `json:"id"`
+		Path    string `json:"path"`
+		Valid   int    `json:"valid"`
+		Invalid int    `json:"invalid"`
+	} `json:"repos"`
+}
+
+type claimValidation struct {
+	ID       string `json:"id"`
+	Content  string `json:"content"`
+	Valid    bool   `json:"valid"`
+	Evidence []struct {
+		ID        string `json:"id"`
+		FilePath  string `json:"file_path"`
+		LineRef   string `json:"line_ref"`
+		GitCommit string `json:"git_commit"`
+		Valid     bool   `json:"valid"`
+		Status    string `json:"status"`
+		RefCommit string `json:"ref_commit"`
+	} `json:"evidence"`
+}
+
+// fetchValidation runs GET /validate with the given query.
+func fetchValidation(client *Client, q url.Values) (*validationReport, error) {
+	body, err := client.get("/validate" + encodeQuery(q))
+	if err != nil {
+		return nil, err
+	}
+	var report validationReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// printClaimValidation prints a claim's status and its evidence that is
+// invalid or has uncommitted edits.
+func printClaimValidation(c claimValidation, indent string) {
+	status := "VALID"
+	if !c.Valid {
+		status = "INVALID"
+	}
+	fmt.Printf("%s[%s] %s  %s\n", indent, status, c.ID, c.Content)
+	for _, ev := range c.Evidence {
+		if !ev.Valid || ev.Status == "dirty" {
+			fmt.Printf("%s    %s: %s  %s  %s  @%s\n", indent, ev.Status, ev.ID, ev.FilePath, ev.LineRef, ev.GitCommit)
+		}
+	}
+}
+
+func validate(client *Client, args []string) error {
+	q := checkQuery(args)
+	q["tag"] = parseFlagValues(args, "--tag")
+	for _, p := range parseFlagValues(args, "--path") {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("resolving path: %v", err)
+		}
+		q.Add("path", abs)
+	}
+	report, err := fetchValidation(client, q)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Claims) == 0 {
+		fmt.Println("No claims.")
+		return nil
+	}
+
+	ref, checked := parseFlag(args, "--ref"), "Checked against"
+	if at := parseFlag(args, "--at"); at != "" {
+		ref, checked = at, "Checked as of"
+	}
+	if ref != "" {
+		var commits []string
+		seen := map[string]bool{}
+		for _, c := range report.Claims {
+			for _, ev := range c.Evidence {
+				if !seen[ev.RefCommit] {
+					seen[ev.RefCommit] = true
+					commits = append(commits, ev.RefCommit)
+				}
+			}
+		}
+		fmt.Printf("%s %s (%s)\n", checked, ref, strings.Join(commits, ", "))
+	}
+
+	for _, c := range report.Claims {
+		printClaimValidation(c, "")
+	}
+	fmt.Printf("%d valid, %d invalid\n", report.Valid, report.Invalid)
+
+	if len(report.Repos) > 1 {
+		fmt.Println("\nEvidence by repository:")
+		for _, r := range report.Repos {
+			fmt.Printf("  %s  %s  %d valid, %d invalid\n", r.ID, r.Path, r.Valid, r.Invalid)
+		}
+	}
+
+	if len(report.Deleted) > 0 {
+		fmt.Printf("\nEvidence citing deleted files (%d):\n", len(report.Deleted))
+		for _, d := range report.Deleted {
+			fmt.Printf("  %s  %s  deleted in %s  cited by %s\n", d.ID, d.FilePath, d.DeletedIn, strings.Join(d.ClaimIDs, ", "))
+		}
+		fmt.Println("Prune with delete-evidence <id> or re-point with post-evidence.")
+	}
+
+	if report.Invalid > 0 {
+		return fmt.Errorf("%d claim(s) have invalid evidence", report.Invalid)
+	}
 	return nil
 }
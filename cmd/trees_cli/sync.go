@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"trees/graph"
+	"trees/store"
+)
+
+// graphRef is the ref the graph is shared through. It holds one file per
+// node, in the layout of store.TreeFiles, and is pushed and fetched like any
+// other ref.
+const graphRef = "refs/trees/graph"
+
+// trackingRef is where the graph last fetched from remote is kept.
+func trackingRef(remote string) string {
+	return "refs/trees/remotes/" + remote + "/graph"
+}
+
+// syncArgs returns the checkout to sync through and the remote named in
+// args, by default origin. The remote must be one configured in the
+// checkout, not a URL or path, since its name is part of its tracking ref.
+func syncArgs(args []string) (top, remote string, err error) {
+	remote = "origin"
+	if rest := positionalArgs(args); len(rest) > 0 {
+		remote = rest[0]
+	}
+	top, err = gitOutput(".", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", fmt.Errorf("finding the checkout: %v (run this inside a git repo)", err)
+	}
+	if _, err := gitOutput(top, "remote", "get-url", "--", remote); err != nil {
+		return "", "", fmt.Errorf("%v (name a remote added with git remote add)", err)
+	}
+	return top, remote, nil
+}
+
+func pull(client *Client, args []string) error {
+	top, remote, err := syncArgs(args)
+	if err != nil {
+		return err
+	}
+	tip, err := fetchGraph(top, remote)
+	if err != nil {
+		return err
+	}
+	if tip == "" {
+		fmt.Printf("No graph on %s yet.\n", remote)
+		return nil
+	}
+	stats, err := mergeFetched(client, top, tip)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pulled graph from %s (%s)\n", remote, shortCommit(tip))
+	printMergeStats(stats)
+	return nil
+}
+
+func push(client *Client, args []string) error {
+	top, remote, err := syncArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// Merge what others pushed first, so the pushed graph includes it and
+	// the push is a fast-forward.
+	remoteTip, err := fetchGraph(top, remote)
+	if err != nil {
+		return err
+	}
+	if remoteTip != "" {
+		stats, err := mergeFetched(client, top, remoteTip)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Merged graph from %s (%s)\n", remote, shortCommit(remoteTip))
+		printMergeStats(stats)
+	}
+
+	body, err := client.get("/graph")
+	if err != nil {
+		return err
+	}
+	g := graph.New()
+	if err := json.Unmarshal(body, g); err != nil {
+		return err
+	}
+	files, err := store.TreeFiles(g)
+	if err != nil {
+		return err
+	}
+
+	localTip, _ := gitOutput(top, "rev-parse", "--verify", "--quiet", graphRef)
+	var parents []string
+	switch {
+	case remoteTip == "" || remoteTip == localTip:
+		if localTip != "" {
+			parents = []string{localTip}
+		}
+	case localTip == "" || isAncestor(top, localTip, remoteTip):
+		parents = []string{remoteTip}
+	case isAncestor(top, remoteTip, localTip):
+		parents = []string{localTip}
+	default:
+		parents = []string{localTip, remoteTip}
+	}
+
+	tip := ""
+	if len(parents) == 1 {
+		current, err := readGraphFiles(top, parents[0])
+		if err != nil {
+			return err
+		}
+		if sameFiles(current, files) {
+			tip = parents[0]
+		}
+	}
+	if tip == "" {
+		msg := fmt.Sprintf("Update claim graph: %d claims, %d evidence nodes\n", len(g.Claims), len(g.Evidence))
+		if tip, err = commitGraph(top, files, parents, msg); err != nil {
+			return err
+		}
+	} else if tip != localTip {
+		if _, err := gitOutput(top, "update-ref", graphRef, tip); err != nil {
+			return err
+		}
+	}
+
+	if _, err := gitRun(top, nil, "push", "--quiet", remote, graphRef+":"+graphRef); err != nil {
+		return fmt.Errorf("%v (the graph on %s changed meanwhile; run push again)", err, remote)
+	}
+	if _, err := gitOutput(top, "update-ref", trackingRef(remote), tip); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed %d claims and %d evidence nodes to %s (%s at %s)\n",
+		len(g.Claims), len(g.Evidence), remote, graphRef, shortCommit(tip))
+	return nil
+}
+
+// fetchGraph fetches remote's graph into its tracking ref and returns the
+// commit, or "" if remote has no graph.
+func fetchGraph(top, remote string) (string, error) {
+	out, err := gitOutput(top, "ls-remote", remote, graphRef)
+	if err != nil {
+		return "", err
+	}
+	if out == "" {
+		return "", nil
+	}
+	if _, err := gitOutput(top, "fetch", "--quiet", remote, "+"+graphRef+":"+trackingRef(remote)); err != nil {
+		return "", err
+	}
+	return gitOutput(top, "rev-parse", trackingRef(remote))
+}
+
+// mergeFetched merges the graph at commit into the server's.
+func mergeFetched(client *Client, top, commit string) (map[string]interface{}, error) {
+	files, err := readGraphFiles(top, commit)
+	if err != nil {
+		return nil, err
+	}
+	g, err := store.GraphFromTreeFiles(files)
+	if err != nil {
+		return nil, fmt.Errorf("reading graph at %s: %v", shortCommit(commit), err)
+	}
+	return client.post("/graph/merge", g)
+}
+
+// readGraphFiles returns the content of every file in commit's tree, by
+// path.
+func readGraphFiles(top, commit string) (map[string][]byte, error) {
+	listing, err := gitRun(top, nil, "ls-tree", "-r", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	var objects bytes.Buffer
+	for _, entry := range bytes.Split(listing, []byte{0}) {
+		// "<mode> <type> <object>\t<path>"
+		meta, path, ok := strings.Cut(string(entry), "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		paths = append(paths, path)
+		objects.WriteString(fields[2] + "\n")
+	}
+	if len(paths) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	out, err := gitRun(top, objects.Bytes(), "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(paths))
+	r := bufio.NewReader(bytes.NewReader(out))
+	for _, path := range paths {
+		// "<object> <type> <size>\n<content>\n"
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("reading %s: unexpected %q", path, strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		r.ReadByte()
+		files[path] = data
+	}
+	return files, nil
+}
+
+// commitGraph commits files as the whole tree of graphRef, on top of
+// parents, with git fast-import, and returns the new commit.
+func commitGraph(top string, files map[string][]byte, parents []string, msg string) (string, error) {
+	ident, err := gitOutput(top, "var", "GIT_COMMITTER_IDENT")
+	if err != nil {
+		ident = fmt.Sprintf("trees-cli <trees-cli> %d +0000", time.Now().Unix())
+	}
+
+	var stream bytes.Buffer
+	fmt.Fprintf(&stream, "commit %s\ncommitter %s\ndata %d\n%s\n", graphRef, ident, len(msg), msg)
+	for i, p := range parents {
+		if i == 0 {
+			fmt.Fprintf(&stream, "from %s\n", p)
+		} else {
+			fmt.Fprintf(&stream, "merge %s\n", p)
+		}
+	}
+	stream.WriteString("deleteall\n")
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(&stream, "M 100644 inline %s\ndata %d\n", p, len(files[p]))
+		stream.Write(files[p])
+		stream.WriteString("\n")
+	}
+	stream.WriteString("done\n")
+
+	if _, err := gitRun(top, stream.Bytes(), "fast-import", "--quiet", "--done"); err != nil {
+		return "", err
+	}
+	return gitOutput(top, "rev-parse", graphRef)
+}
+
+// isAncestor reports whether commit a is an ancestor of commit b.
+func isAncestor(top, a, b string) bool {
+	_, err := gitRun(top, nil, "merge-base", "--is-ancestor", a, b)
+	return err == nil
+}
+
+func sameFiles(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, data := range a {
+		if other, ok := b[p]; !ok || !bytes.Equal(data, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// printMergeStats prints the decoded result of POST /graph/merge.
+func printMergeStats(stats map[string]interface{}) {
+	fmt.Printf("  claims: %v added, %v updated\n", stats["claims_added"], stats["claims_updated"])
+	fmt.Printf("  evidence: %v added, %v updated\n", stats["evidence_added"], stats["evidence_updated"])
+	fmt.Printf("  links: %v added, %v updated\n", stats["edges_added"], stats["edges_updated"])
+	if n, ok := stats["repos_added"].(float64); ok && n > 0 {
+		fmt.Printf("  repositories: %v added\n", n)
+	}
+}
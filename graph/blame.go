@@ -0,0 +1,26 @@
+package graph
+
+import "fmt"
+
+// Blame finds the first commit after an evidence node's recorded commit that
+// changed the lines it cites, or returns nil if none did. Returns an error if
+// the evidence is not found, the checker cannot follow lines through
+// history, or the git check fails.
+func (g *Graph) Blame(id string, checker GitChecker) (*Change, error) {
+	ev, ok := g.Evidence[id]
+	if !ok {
+		return nil, fmt.Errorf("evidence %q not found", id)
+	}
+	if ev.FilePath == "" {
+		return nil, errNoCheckout(ev)
+	}
+	blamer, ok := Capability[LineBlamer](checker)
+	if !ok {
+		return nil, fmt.Errorf("finding the commit that changed cited lines is not supported by this checker")
+	}
+	lines, err := ParseLineRef(ev.LineRef)
+	if err != nil {
+		return nil, err
+	}
+	return blamer.FirstChange(ev.GitCommit, ev.FilePath, lines)
+}
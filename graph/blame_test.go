@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+// mockBlamer is a mockGitChecker that reports a fixed change for any lines.
+type mockBlamer struct {
+	mockGitChecker
+	change *Change
+	asked  []LineRange
+}
+
+func (m *mockBlamer) FirstChange(commit, filePath string, lines []LineRange) (*Change, error) {
+	m.asked = lines
+	return m.change, nil
+}
+
+func TestBlame(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10-12,20", "abc123")
+
+	checker := &mockBlamer{change: &Change{Commit: "def456", Author: "A"}}
+	change, err := g.Blame(ev.ID, checker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change == nil || change.Commit != "def456" {
+		t.Errorf("expected change def456, got %+v", change)
+	}
+	if len(checker.asked) != 2 || checker.asked[1] != (LineRange{20, 20}) {
+		t.Errorf("expected the cited lines to be asked about, got %v", checker.asked)
+	}
+
+	if _, err := g.Blame(ev.ID, &mockGitChecker{}); err == nil {
+		t.Error("expected an error from a checker that cannot blame lines")
+	}
+	if _, err := g.Blame("nonexistent", checker); err == nil {
+		t.Error("expected an error for missing evidence")
+	}
+}
+
+func TestExecGitCheckerFirstChange(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n\nfunc A() int {\n\treturn 1\n}\n")
+	cited := r.commit("add A")
+	lines := []LineRange{{3, 5}}
+	checker := &ExecGitChecker{}
+
+	change, err := checker.FirstChange(cited, r.path("a.go"), lines)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change != nil {
+		t.Errorf("expected no change yet, got %+v", change)
+	}
+
+	r.write("a.go", "package a\n\n// A returns one.\n// It always has.\nfunc A() int {\n\treturn 1\n}\n")
+	r.commit("document A")
+	r.write("a.go", "package a\n\n// A returns one.\n// It always has.\nfunc A() int {\n\treturn 2\n}\n")
+	edit := r.commit("make A return two\n\nOne was too small.")
+	r.write("a.go", "package a\n\n// A returns two.\nfunc A() int {\n\treturn 3\n}\n")
+	r.commit("make A return three")
+
+	change, err = checker.FirstChange(cited, r.path("a.go"), lines)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if change == nil {
+		t.Fatal("expected a change")
+	}
+	if change.Commit != edit {
+		t.Errorf("expected commit %s, got %s", edit, change.Commit)
+	}
+	if change.Author != "A" || change.Email != "a@example.com" {
+		t.Errorf("expected author A <a@example.com>, got %s <%s>", change.Author, change.Email)
+	}
+	if !strings.HasPrefix(change.Message, "make A return two") || !strings.Contains(change.Message, "too small") {
+		t.Errorf("expected the full commit message, got %q", change.Message)
+	}
+	if change.Date.IsZero() {
+		t.Error("expected a commit date")
+	}
+}
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// diffHunk is one hunk header of a unified diff: the lines it replaces in
+// the old file and the lines it introduces in the new one. A count of zero
+// means the hunk only adds or only removes lines, with the start pointing
+// at the line before the change.
+type diffHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+}
+
+// oldRange returns the old-file lines the hunk touches, using the empty
+// range {n+1, n} for a pure insertion after line n.
+func (h diffHunk) oldRange() LineRange {
+	if h.OldLines == 0 {
+		return LineRange{Start: h.OldStart + 1, End: h.OldStart}
+	}
+	return LineRange{Start: h.OldStart, End: h.OldStart + h.OldLines - 1}
+}
+
+// String returns the hunk's ranges as in its header, "-start,count
+// +start,count".
+func (h diffHunk) String() string {
+	return fmt.Sprintf("-%d,%d +%d,%d", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// parseHunks extracts the hunk headers from unified diff output, skipping
+// everything else.
+func parseHunks(diff []byte) []diffHunk {
+	var hunks []diffHunk
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if h, ok := parseHunkHeader(scanner.Text()); ok {
+			hunks = append(hunks, h)
+		}
+	}
+	return hunks
+}
+
+// parseHunkHeader parses a "@@ -old +new @@" line.
+func parseHunkHeader(line string) (diffHunk, bool) {
+	if !strings.HasPrefix(line, "@@ -") {
+		return diffHunk{}, false
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return diffHunk{}, false
+	}
+	oldStart, oldLines, ok1 := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+	newStart, newLines, ok2 := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+	if !ok1 || !ok2 {
+		return diffHunk{}, false
+	}
+	return diffHunk{oldStart, oldLines, newStart, newLines}, true
+}
+
+// fileDiff is the part of a patch that changes one file. OldPath is empty
+// for a file the patch creates and NewPath for one it deletes.
+type fileDiff struct {
+	OldPath, NewPath string
+	// Hunks are the runs of changed lines, without the context lines
+	// around them, in the same form as hunk headers.
+	Hunks []diffHunk
+}
+
+// parsePatch splits a unified diff covering any number of files, such as
+// the output of git diff, into the changes to each file. Paths are as the
+// patch gives them, without git's a/ and b/ prefixes. Hunk bodies are read
+// line by line, so context lines are not counted as changed and removed
+// lines that look like file headers are not mistaken for them.
+func parsePatch(patch []byte) []fileDiff {
+	var files []fileDiff
+	// Lines left in the current hunk, the next old and new line numbers,
+	// and the run of changes being collected.
+	var oldLeft, newLeft, oldLine, newLine int
+	var run *diffHunk
+	endRun := func() {
+		if run == nil {
+			return
+		}
+		if run.OldLines == 0 {
+			run.OldStart--
+		}
+		if run.NewLines == 0 {
+			run.NewStart--
+		}
+		f := &files[len(files)-1]
+		f.Hunks = append(f.Hunks, *run)
+		run = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(patch))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			if strings.HasPrefix(line, "\\") {
+				continue // "\ No newline at end of file"
+			}
+			removed, added := strings.HasPrefix(line, "-"), strings.HasPrefix(line, "+")
+			if !removed && !added {
+				endRun()
+				oldLine, newLine = oldLine+1, newLine+1
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+				continue
+			}
+			if run == nil {
+				run = &diffHunk{OldStart: oldLine, NewStart: newLine}
+			}
+			if removed {
+				run.OldLines++
+				oldLine, oldLeft = oldLine+1, oldLeft-1
+			} else {
+				run.NewLines++
+				newLine, newLeft = newLine+1, newLeft-1
+			}
+			if oldLeft <= 0 && newLeft <= 0 {
+				endRun()
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			files = append(files, fileDiff{OldPath: patchPath(line[4:], "a/")})
+		case strings.HasPrefix(line, "+++ ") && len(files) > 0:
+			files[len(files)-1].NewPath = patchPath(line[4:], "b/")
+		case len(files) > 0:
+			if h, ok := parseHunkHeader(line); ok {
+				oldLeft, newLeft = h.OldLines, h.NewLines
+				oldLine, newLine = h.OldStart, h.NewStart
+				// A side with no lines starts at the line before.
+				if h.OldLines == 0 {
+					oldLine++
+				}
+				if h.NewLines == 0 {
+					newLine++
+				}
+			}
+		}
+	}
+	return files
+}
+
+// patchPath extracts the path from the rest of a "---" or "+++" line,
+// dropping any timestamp after a tab, unquoting a path git quoted and
+// removing prefix. Returns "" for /dev/null.
+func patchPath(s, prefix string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseHunkRange parses "start,count" or "start", where a missing count
+// means one line.
+func parseHunkRange(s string) (start, count int, ok bool) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
+
+// rangesTouched reports whether any of changed overlaps lines. An empty
+// changed range {n+1, n} is an insertion between lines n and n+1 and only
+// touches a range that spans both.
+func rangesTouched(lines, changed []LineRange) bool {
+	for _, c := range changed {
+		for _, l := range lines {
+			if c.Start > c.End {
+				if l.Start < c.Start && c.Start <= l.End {
+					return true
+				}
+				continue
+			}
+			if c.Start <= l.End && l.Start <= c.End {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shiftRanges maps lines that no hunk touches from the old file of a diff
+// to where they are in the new one.
+func shiftRanges(lines []LineRange, hunks []diffHunk) []LineRange {
+	shifted := make([]LineRange, len(lines))
+	for i, l := range lines {
+		delta := 0
+		for _, h := range hunks {
+			if h.oldRange().End < l.Start {
+				delta += h.NewLines - h.OldLines
+			}
+		}
+		shifted[i] = LineRange{Start: l.Start + delta, End: l.End + delta}
+	}
+	return shifted
+}
@@ -0,0 +1,104 @@
+package graph
+
+import "testing"
+
+func TestParseHunks(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n" +
+		"--- a/a.go\n" +
+		"+++ b/a.go\n" +
+		"@@ -3,2 +3,3 @@ func A() {\n" +
+		"-\told\n" +
+		"+\tnew\n" +
+		"@@ -10 +11 @@\n" +
+		"@@ -20,0 +22,2 @@\n"
+
+	hunks := parseHunks([]byte(diff))
+	expected := []diffHunk{{3, 2, 3, 3}, {10, 1, 11, 1}, {20, 0, 22, 2}}
+	if len(hunks) != len(expected) {
+		t.Fatalf("expected %d hunks, got %d", len(expected), len(hunks))
+	}
+	for i, h := range hunks {
+		if h != expected[i] {
+			t.Errorf("hunk %d: expected %+v, got %+v", i, expected[i], h)
+		}
+	}
+	if r := hunks[2].oldRange(); r != (LineRange{Start: 21, End: 20}) {
+		t.Errorf("expected insertion range {21, 20}, got %v", r)
+	}
+}
+
+func TestRangesTouched(t *testing.T) {
+	tests := []struct {
+		changed LineRange
+		touched bool
+	}{
+		{LineRange{12, 12}, true},
+		{LineRange{5, 10}, true},
+		{LineRange{16, 20}, false},
+		{LineRange{12, 11}, true},  // insertion between 11 and 12
+		{LineRange{10, 9}, false},  // insertion just before the range
+		{LineRange{16, 15}, false}, // insertion just after the range
+	}
+	for _, tt := range tests {
+		if got := rangesTouched([]LineRange{{Start: 10, End: 15}}, []LineRange{tt.changed}); got != tt.touched {
+			t.Errorf("changed %v: expected touched=%v, got %v", tt.changed, tt.touched, got)
+		}
+	}
+}
+
+func TestShiftRanges(t *testing.T) {
+	hunks := []diffHunk{
+		{2, 1, 2, 3},   // line 2 became three lines
+		{5, 2, 7, 0},   // lines 5-6 removed
+		{20, 0, 19, 1}, // insertion after line 20
+	}
+	got := shiftRanges([]LineRange{{10, 12}, {1, 1}, {25, 25}}, hunks)
+	expected := []LineRange{{10, 12}, {1, 1}, {26, 26}}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("range %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	patch := "diff --git a/a.go b/a.go\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/a.go\n" +
+		"+++ b/a.go\n" +
+		"@@ -3,3 +3,2 @@ func A() {\n" +
+		" \tx := 1\n" +
+		"--- not a header, a removed line\n" +
+		" \treturn x\n" +
+		"@@ -10 +9,0 @@\n" +
+		"-\tgone\n" +
+		"\\ No newline at end of file\n" +
+		"diff --git a/new.go b/new.go\n" +
+		"new file mode 100644\n" +
+		"--- /dev/null\n" +
+		"+++ b/new.go\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+package a\n" +
+		"diff --git \"a/sp ace.go\" \"b/sp ace.go\"\n" +
+		"--- \"a/sp ace.go\"\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-package a\n"
+
+	files := parsePatch([]byte(patch))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d: %+v", len(files), files)
+	}
+	if f := files[0]; f.OldPath != "a.go" || f.NewPath != "a.go" || len(f.Hunks) != 2 || f.Hunks[1] != (diffHunk{10, 1, 9, 0}) {
+		t.Errorf("expected a.go with 2 hunks, got %+v", f)
+	}
+	if f := files[1]; f.OldPath != "" || f.NewPath != "new.go" || len(f.Hunks) != 1 {
+		t.Errorf("expected created new.go, got %+v", f)
+	}
+	if f := files[2]; f.OldPath != "sp ace.go" || f.NewPath != "" {
+		t.Errorf("expected deleted \"sp ace.go\", got %+v", f)
+	}
+	if s := files[0].Hunks[0].String(); s != "-4,1 +3,0" {
+		t.Errorf("expected only the removed line, \"-4,1 +3,0\", got %q", s)
+	}
+}
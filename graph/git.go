@@ -1,5 +1,7 @@
 package graph
 
+import "time"
+
 // GitChecker determines whether a file has changed since a given commit.
 // Implementations wrap the actual git CLI (Humble Object pattern).
 type GitChecker interface {
@@ -7,3 +9,110 @@ type GitChecker interface {
 	// modified in any commit after the given commit hash.
 	HasFileChangedSince(commit, filePath string) (bool, error)
 }
+
+// CheckerWrapper is implemented by GitCheckers that add to another checker,
+// such as CachingChecker. Their optional capabilities are those of the
+// checker they wrap.
+type CheckerWrapper interface {
+	Unwrap() GitChecker
+}
+
+// Capability returns checker as a T, the optional capability interface such
+// as RevisionReader, looking through CheckerWrappers to the first checker
+// that implements it. ok is false if none does.
+func Capability[T any](checker GitChecker) (capability T, ok bool) {
+	for checker != nil {
+		if capability, ok = checker.(T); ok {
+			return capability, true
+		}
+		wrapper, isWrapper := checker.(CheckerWrapper)
+		if !isWrapper {
+			break
+		}
+		checker = wrapper.Unwrap()
+	}
+	return capability, false
+}
+
+// RevisionReader reads file contents as of a given revision. GitCheckers
+// that also implement it enable features that need the cited text itself.
+type RevisionReader interface {
+	// ReadFileAt returns the contents of the file at filePath as of rev,
+	// which may be a commit hash, a ref name or "HEAD".
+	ReadFileAt(rev, filePath string) ([]byte, error)
+}
+
+// RenameTracker follows a file across renames and moves.
+type RenameTracker interface {
+	// CurrentPath returns the absolute path at HEAD of the file that was at
+	// filePath as of commit, or filePath itself if it was not renamed.
+	CurrentPath(commit, filePath string) (string, error)
+}
+
+// HeadResolver reports the commit checked out in a file's repository.
+type HeadResolver interface {
+	// HeadCommit returns the full hash of HEAD in the repository containing
+	// filePath.
+	HeadCommit(filePath string) (string, error)
+}
+
+// DeletionTracker finds the commit that deleted a file.
+type DeletionTracker interface {
+	// DeletingCommit returns the most recent commit after commit that
+	// deleted the file at filePath, or "" if the file still exists at HEAD.
+	DeletingCommit(commit, filePath string) (string, error)
+}
+
+// WorkingTreeChecker reports uncommitted changes to a file.
+type WorkingTreeChecker interface {
+	// UncommittedLines returns the line ranges of the file at HEAD that are
+	// modified or removed in the index or working tree. A pure insertion
+	// between lines n and n+1 is reported as the empty range {n+1, n}.
+	UncommittedLines(filePath string) ([]LineRange, error)
+}
+
+// RefScoper scopes a checker to a branch, tag or commit other than HEAD.
+type RefScoper interface {
+	// AtRef returns a checker that treats ref wherever it would otherwise
+	// use HEAD.
+	AtRef(ref string) GitChecker
+}
+
+// RepoIdentifier names the repository a file belongs to in a way that is
+// the same in every clone.
+type RepoIdentifier interface {
+	// RepoIdentity returns the identity of the repository containing
+	// filePath and the file's slash-separated path relative to its root.
+	RepoIdentity(filePath string) (repo, relPath string, err error)
+}
+
+// BranchResolver reports a repository's default branch.
+type BranchResolver interface {
+	// DefaultBranch returns the branch the repository containing filePath
+	// treats as its mainline, as a ref usable in validity checks.
+	DefaultBranch(filePath string) (string, error)
+}
+
+// AncestryChecker relates commits in a file's repository.
+type AncestryChecker interface {
+	// IsAncestor reports whether ancestor is reachable from commit in the
+	// repository containing filePath. A commit is its own ancestor.
+	IsAncestor(ancestor, commit, filePath string) (bool, error)
+}
+
+// Change describes a commit that modified cited code.
+type Change struct {
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+}
+
+// LineBlamer finds the commit that first changed cited lines.
+type LineBlamer interface {
+	// FirstChange follows lines of the file at filePath as of commit
+	// forward through history to HEAD, and returns the first commit that
+	// modifies any of them or removes the file, or nil if none does.
+	FirstChange(commit, filePath string, lines []LineRange) (*Change, error)
+}
@@ -0,0 +1,279 @@
+package graph
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachingChecker decorates another GitChecker, memoizing evidence validity
+// per repository checkout, commit checked against, cited commit, path and
+// lines. Validity only depends on committed history, so a result stays good
+// for as long as HEAD, or the ref checked against, names the same commit.
+// What a ref names is itself trusted for headTTL, or until RefsChanged says
+// it moved, so most lookups run no git at all. Results are kept for the
+// latest maxCachedCommits commits checked against in each checkout. Working
+// tree checks are never cached. Create it with NewCachingChecker.
+//
+// Caching requires the wrapped checker to be a HeadResolver. The wrapped
+// checker's other capabilities are found through Capability, so a
+// CachingChecker supports exactly what the checker it wraps does.
+type CachingChecker struct {
+	inner GitChecker
+	// ref is the ref inner is scoped to, if any.
+	ref   string
+	cache *validityCache
+}
+
+// headTTL is how long the commit a ref names is trusted before it is
+// resolved again.
+const headTTL = time.Second
+
+// maxCachedCommits bounds the commits each checkout keeps results for. HEAD's
+// commit is the one usually asked about; others come from checks against
+// other refs, and the least recently used is dropped first.
+const maxCachedCommits = 8
+
+// CacheStats counts validity lookups answered from the cache and those that
+// had to ask git.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// validityCache is shared by a CachingChecker and the checkers it scopes to
+// other refs.
+type validityCache struct {
+	mu  sync.Mutex
+	now func() time.Time
+	// heads holds the commit each ref was found to name in each checkout.
+	heads map[headKey]resolvedHead
+	// repos holds the cached results for each checkout by the commit they
+	// were computed against.
+	repos  map[string]map[string]*commitCache
+	tick   uint64
+	hits   uint64
+	misses uint64
+}
+
+type headKey struct {
+	repo string
+	ref  string
+}
+
+type resolvedHead struct {
+	commit string
+	at     time.Time
+}
+
+type commitCache struct {
+	used    uint64
+	results map[validityKey]Validity
+}
+
+type validityKey struct {
+	commit    string
+	path      string
+	lines     string
+	symbol    string
+	normalize bool
+}
+
+// ValidityCache is a GitChecker that memoizes evidence validity.
+// EvidenceValidity asks it for validity instead of checking the evidence
+// itself.
+type ValidityCache interface {
+	GitChecker
+	// CachedValidity returns the validity of ev against the checker's HEAD,
+	// without its Status, from the cache when it was computed against the
+	// commit HEAD names.
+	CachedValidity(ev *EvidenceNode, opts CheckOptions) (Validity, error)
+	// CachedHead returns the commit the checker's HEAD names in ev's
+	// checkout, resolving it only when it is no longer trusted.
+	CachedHead(ev *EvidenceNode) (string, error)
+	// RefsChanged tells the cache that refs in the checkout at dir may have
+	// moved, so what they name is resolved again.
+	RefsChanged(dir string)
+	// Stats returns the hit and miss counts since the cache was created,
+	// and the number of results currently cached.
+	Stats() CacheStats
+}
+
+// NewCachingChecker returns a checker that caches the validity results of
+// inner. It can be scoped to a ref when inner is a RefScoper, and its
+// checkers for other refs share the cache.
+func NewCachingChecker(inner GitChecker) ValidityCache {
+	return newCachingChecker(inner, "", &validityCache{
+		now:   time.Now,
+		heads: make(map[headKey]resolvedHead),
+		repos: make(map[string]map[string]*commitCache),
+	})
+}
+
+func newCachingChecker(inner GitChecker, ref string, cache *validityCache) ValidityCache {
+	c := &CachingChecker{inner: inner, ref: ref, cache: cache}
+	if _, ok := inner.(RefScoper); ok {
+		return &scopingCachingChecker{c}
+	}
+	return c
+}
+
+// scopingCachingChecker is a CachingChecker around a RefScoper.
+type scopingCachingChecker struct {
+	*CachingChecker
+}
+
+func (c *scopingCachingChecker) AtRef(ref string) GitChecker {
+	return newCachingChecker(c.inner.(RefScoper).AtRef(ref), ref, c.cache)
+}
+
+// Unwrap returns the checker c caches the results of.
+func (c *CachingChecker) Unwrap() GitChecker {
+	return c.inner
+}
+
+func (c *CachingChecker) Stats() CacheStats {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	stats := CacheStats{Hits: c.cache.hits, Misses: c.cache.misses}
+	for _, commits := range c.cache.repos {
+		for _, entry := range commits {
+			stats.Entries += len(entry.results)
+		}
+	}
+	return stats
+}
+
+func (c *CachingChecker) RefsChanged(dir string) {
+	dir = filepath.Clean(dir)
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	for key := range c.cache.heads {
+		if key.repo == dir || strings.HasPrefix(key.repo, dir+string(filepath.Separator)) {
+			delete(c.cache.heads, key)
+		}
+	}
+}
+
+// head returns the commit the checker's HEAD names in the checkout repo,
+// resolving it through resolver when it is not known or no longer trusted.
+func (c *CachingChecker) head(resolver HeadResolver, repo, filePath string) (string, error) {
+	key := headKey{repo, c.ref}
+	c.cache.mu.Lock()
+	h, ok := c.cache.heads[key]
+	now := c.cache.now()
+	c.cache.mu.Unlock()
+	if ok && now.Sub(h.at) < headTTL {
+		return h.commit, nil
+	}
+
+	commit, err := resolver.HeadCommit(filePath)
+	if err != nil {
+		return "", err
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	// Refs resolved long enough ago are dropped, so refs named once by a
+	// client do not pile up.
+	for k, h := range c.cache.heads {
+		if now.Sub(h.at) >= headTTL {
+			delete(c.cache.heads, k)
+		}
+	}
+	c.cache.heads[key] = resolvedHead{commit: commit, at: now}
+	return commit, nil
+}
+
+// checkout returns the directory ev's results are cached under: its
+// checkout, or its file's directory when its checkout is not known.
+func checkout(ev *EvidenceNode) string {
+	if repo := ev.CheckoutRoot(); repo != "" {
+		return repo
+	}
+	return filepath.Dir(ev.FilePath)
+}
+
+func (c *CachingChecker) CachedHead(ev *EvidenceNode) (string, error) {
+	resolver, ok := Capability[HeadResolver](c.inner)
+	if !ok {
+		return "", fmt.Errorf("resolving HEAD is not supported by this checker")
+	}
+	return c.head(resolver, checkout(ev), ev.FilePath)
+}
+
+func (c *CachingChecker) CachedValidity(ev *EvidenceNode, opts CheckOptions) (Validity, error) {
+	resolver, ok := Capability[HeadResolver](c.inner)
+	if !ok {
+		return evidenceValidity(ev, c.inner, opts)
+	}
+	repo := checkout(ev)
+	head, err := c.head(resolver, repo, ev.FilePath)
+	if err != nil {
+		return evidenceValidity(ev, c.inner, opts)
+	}
+	key := validityKey{
+		commit:    ev.GitCommit,
+		path:      ev.FilePath,
+		lines:     ev.LineRef,
+		symbol:    ev.Symbol,
+		normalize: opts.Normalize,
+	}
+
+	c.cache.mu.Lock()
+	if entry := c.cache.repos[repo][head]; entry != nil {
+		if v, ok := entry.results[key]; ok {
+			c.cache.tick++
+			entry.used = c.cache.tick
+			c.cache.hits++
+			c.cache.mu.Unlock()
+			return v, nil
+		}
+	}
+	c.cache.misses++
+	c.cache.mu.Unlock()
+
+	v, err := evidenceValidity(ev, c.inner, opts)
+	if err != nil {
+		return Validity{}, err
+	}
+	c.cache.store(repo, head, key, v)
+	return v, nil
+}
+
+// store records v as the validity for key against commit head in the
+// checkout repo, dropping the least recently used commit's results if the
+// checkout then has results for more than maxCachedCommits commits.
+func (c *validityCache) store(repo, head string, key validityKey, v Validity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	commits := c.repos[repo]
+	if commits == nil {
+		commits = make(map[string]*commitCache)
+		c.repos[repo] = commits
+	}
+	entry := commits[head]
+	if entry == nil {
+		entry = &commitCache{results: make(map[validityKey]Validity)}
+		commits[head] = entry
+	}
+	c.tick++
+	entry.used = c.tick
+	entry.results[key] = v
+	if len(commits) <= maxCachedCommits {
+		return
+	}
+	oldest := ""
+	for commit, e := range commits {
+		if oldest == "" || e.used < commits[oldest].used {
+			oldest = commit
+		}
+	}
+	delete(commits, oldest)
+}
+
+func (c *CachingChecker) HasFileChangedSince(commit, filePath string) (bool, error) {
+	return c.inner.HasFileChangedSince(commit, filePath)
+}
@@ -0,0 +1,226 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingChecker counts the git checks that reach a mockRefChecker.
+type countingChecker struct {
+	*mockRefChecker
+	calls *int
+}
+
+func (m *countingChecker) HasFileChangedSince(commit, filePath string) (bool, error) {
+	*m.calls++
+	return m.mockRefChecker.HasFileChangedSince(commit, filePath)
+}
+
+func (m *countingChecker) AtRef(ref string) GitChecker {
+	return &countingChecker{m.mockRefChecker.AtRef(ref).(*mockRefChecker), m.calls}
+}
+
+func TestCachingCheckerInvalidatesOnHeadChange(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	calls := 0
+	inner := &mockRefChecker{
+		commits: map[string]string{"": "head1", "origin/main": "def456"},
+		changed: map[string][]string{"origin/main": {"/home/user/auth.go"}},
+	}
+	checker := NewCachingChecker(&countingChecker{inner, &calls})
+
+	for i := 0; i < 3; i++ {
+		v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !v.Valid || v.Status != EvidenceValid {
+			t.Errorf("expected valid evidence, got %+v", v)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 git check, got %d", calls)
+	}
+	if stats := checker.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("expected 2 hits, 1 miss and 1 entry, got %+v", stats)
+	}
+
+	// Another line reference is a separate entry.
+	ev.LineRef = "11"
+	g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if calls != 2 {
+		t.Errorf("expected 2 git checks, got %d", calls)
+	}
+
+	// Once HEAD is known to have moved, results are computed against the
+	// commit it names now.
+	inner.commits[""] = "head2"
+	inner.changed[""] = []string{"/home/user/auth.go"}
+	checker.RefsChanged("/home/user")
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected evidence to be stale after HEAD moved")
+	}
+	if stats := checker.Stats(); stats.Misses != 3 || stats.Entries != 3 {
+		t.Errorf("expected 3 misses and 3 entries, got %+v", stats)
+	}
+}
+
+func TestCachingCheckerTrustsHeadBriefly(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	inner := &countingHeadChecker{mockRefChecker: &mockRefChecker{
+		commits: map[string]string{"": "head1"},
+		changed: map[string][]string{},
+	}}
+	checker := NewCachingChecker(inner)
+	now := time.Now()
+	checker.(*scopingCachingChecker).cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	}
+	if inner.heads != 1 {
+		t.Errorf("expected HEAD resolved once, got %d", inner.heads)
+	}
+
+	inner.commits[""] = "head2"
+	inner.changed[""] = []string{"/home/user/auth.go"}
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{}); !v.Valid {
+		t.Error("expected the result for the trusted HEAD")
+	}
+	now = now.Add(headTTL)
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{}); v.Valid {
+		t.Error("expected HEAD resolved again once no longer trusted")
+	}
+	if inner.heads != 2 {
+		t.Errorf("expected HEAD resolved twice, got %d", inner.heads)
+	}
+}
+
+func TestCachingCheckerBoundsCommits(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	inner := &mockRefChecker{commits: map[string]string{"": "head1"}, changed: map[string][]string{}}
+	for i := 0; i < 2*maxCachedCommits; i++ {
+		ref := fmt.Sprintf("branch%d", i)
+		inner.commits[ref] = fmt.Sprintf("commit%d", i)
+	}
+	checker := NewCachingChecker(inner)
+	for i := 0; i < 2*maxCachedCommits; i++ {
+		g.EvidenceValidity(ev.ID, checker, CheckOptions{Ref: fmt.Sprintf("branch%d", i)})
+	}
+	if stats := checker.Stats(); stats.Entries != maxCachedCommits {
+		t.Errorf("expected results for %d commits, got %+v", maxCachedCommits, stats)
+	}
+}
+
+func TestCachingCheckerAtRef(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	calls := 0
+	inner := &mockRefChecker{
+		commits: map[string]string{"": "head1", "origin/main": "def456"},
+		changed: map[string][]string{"origin/main": {"/home/user/auth.go"}},
+	}
+	checker := NewCachingChecker(&countingChecker{inner, &calls})
+
+	for i := 0; i < 2; i++ {
+		v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Ref: "origin/main"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v.Valid || v.RefCommit != "def456" {
+			t.Errorf("expected stale evidence at def456, got %+v", v)
+		}
+		v, err = g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !v.Valid {
+			t.Error("expected valid evidence at HEAD")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected one git check per ref, got %d", calls)
+	}
+	if stats := checker.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("expected 2 hits and 2 misses, got %+v", stats)
+	}
+}
+
+func TestCachingCheckerPinnedToHead(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	calls := 0
+	head := strings.Repeat("1", 40)
+	inner := &mockRefChecker{commits: map[string]string{"": head, head: head}}
+	checker := NewCachingChecker(&countingChecker{inner, &calls})
+	pinned, err := ScopeChecker(checker, head)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	g.EvidenceValidity(ev.ID, pinned, CheckOptions{})
+	if calls != 1 {
+		t.Errorf("expected the checker pinned to HEAD's commit to share its results, got %d git checks", calls)
+	}
+	if stats := checker.Stats(); stats.Entries != 1 {
+		t.Errorf("expected 1 entry, got %+v", stats)
+	}
+}
+
+func TestCachingCheckerWithoutHeadResolver(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+	checker := NewCachingChecker(&mockGitChecker{changed: map[string]bool{"abc123:/home/user/auth.go": true}})
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected evidence to be stale")
+	}
+	if stats := checker.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("expected nothing cached, got %+v", stats)
+	}
+	if _, ok := Capability[RevisionReader](checker); ok {
+		t.Error("expected no RevisionReader when the wrapped checker lacks it")
+	}
+	if _, err := ScopeChecker(checker, "origin/main"); err == nil {
+		t.Error("expected an error scoping a checker whose wrapped checker cannot be scoped")
+	}
+}
+
+func TestCachingCheckerCapabilities(t *testing.T) {
+	checker := NewCachingChecker(NewNativeGitChecker())
+	if _, ok := Capability[RevisionReader](checker); !ok {
+		t.Error("expected the native checker's RevisionReader")
+	}
+	if _, ok := Capability[LineBlamer](checker); ok {
+		t.Error("expected no LineBlamer, which the native checker lacks")
+	}
+	scoped, err := ScopeChecker(checker, "main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := scoped.(ValidityCache); !ok {
+		t.Errorf("expected the scoped checker to keep caching, got %T", scoped)
+	}
+	if _, ok := Capability[HeadResolver](scoped); !ok {
+		t.Error("expected the scoped checker's HeadResolver")
+	}
+}
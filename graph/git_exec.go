@@ -1,21 +1,304 @@
 package graph
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ExecGitChecker implements GitChecker by shelling out to git.
-type ExecGitChecker struct{}
+type ExecGitChecker struct {
+	// Ref is checked against in place of HEAD when set.
+	Ref string
+}
+
+func (c *ExecGitChecker) AtRef(ref string) GitChecker {
+	return &ExecGitChecker{Ref: ref}
+}
+
+// head returns the revision the checker treats as HEAD.
+func (c *ExecGitChecker) head() string {
+	if c.Ref != "" {
+		return c.Ref
+	}
+	return "HEAD"
+}
 
 func (c *ExecGitChecker) HasFileChangedSince(commit, filePath string) (bool, error) {
-	dir := filepath.Dir(filePath)
-	cmd := exec.Command("git", "log", "--oneline", commit+"..HEAD", "--", filePath)
-	cmd.Dir = dir
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return false, err
+	}
+	cmd := exec.Command("git", "log", "--oneline", "--end-of-options", commit+".."+c.head(), "--", rel)
+	cmd.Dir = top
 	out, err := cmd.Output()
 	if err != nil {
 		return false, err
 	}
 	return strings.TrimSpace(string(out)) != "", nil
 }
+
+func (c *ExecGitChecker) ReadFileAt(rev, filePath string) ([]byte, error) {
+	if rev == "HEAD" {
+		rev = c.head()
+	}
+	// The file's directory may be gone at HEAD, so run git from the top
+	// level and name the file from there.
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "show", "--end-of-options", rev+":"+rel)
+	cmd.Dir = top
+	return cmd.Output()
+}
+
+func (c *ExecGitChecker) CurrentPath(commit, filePath string) (string, error) {
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", "diff", "-z", "--name-status", "-M", "--diff-filter=R", "--end-of-options", commit, c.head())
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	// Records are "R<score>\0<old path>\0<new path>\0".
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i+2 < len(fields); i += 3 {
+		if string(fields[i+1]) == rel {
+			return filepath.Join(top, filepath.FromSlash(string(fields[i+2]))), nil
+		}
+	}
+	return filePath, nil
+}
+
+func (c *ExecGitChecker) DeletingCommit(commit, filePath string) (string, error) {
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	exists := exec.Command("git", "cat-file", "-e", "--end-of-options", c.head()+":"+rel)
+	exists.Dir = top
+	if exists.Run() == nil {
+		return "", nil
+	}
+	cmd := exec.Command("git", "log", "--diff-filter=D", "--format=%H", "--end-of-options", commit+".."+c.head(), "--", rel)
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	deleting, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return deleting, nil
+}
+
+func (c *ExecGitChecker) UncommittedLines(filePath string) ([]LineRange, error) {
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// Uncommitted edits are relative to HEAD even when the checker is
+	// scoped to another ref.
+	cmd := exec.Command("git", "diff", "-U0", "--no-color", "--no-ext-diff", "HEAD", "--", rel)
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var lines []LineRange
+	for _, h := range parseHunks(out) {
+		lines = append(lines, h.oldRange())
+	}
+	return lines, nil
+}
+
+func (c *ExecGitChecker) HeadCommit(filePath string) (string, error) {
+	top, _, err := repoPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "--end-of-options", c.head()+"^{commit}")
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q in %s", c.head(), top)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (c *ExecGitChecker) IsAncestor(ancestor, commit, filePath string) (bool, error) {
+	top, _, err := repoPath(filePath)
+	if err != nil {
+		return false, err
+	}
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", "--end-of-options", ancestor, commit)
+	cmd.Dir = top
+	err = cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot relate %q and %q in %s", ancestor, commit, top)
+	}
+	return true, nil
+}
+
+// FirstChange walks the first-parent history from commit to HEAD that
+// touches the file, diffing each step and moving the lines past edits
+// elsewhere in the file.
+func (c *ExecGitChecker) FirstChange(commit, filePath string, lines []LineRange) (*Change, error) {
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "rev-list", "--reverse", "--first-parent", "--end-of-options", commit+".."+c.head(), "--", rel)
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	prev := commit
+	for _, next := range strings.Fields(string(out)) {
+		cmd := exec.Command("git", "diff", "-U0", "--no-color", "--no-ext-diff", "--end-of-options", prev, next, "--", rel)
+		cmd.Dir = top
+		diff, err := cmd.Output()
+		if err != nil {
+			return nil, err
+		}
+		hunks := parseHunks(diff)
+		changed := make([]LineRange, len(hunks))
+		for i, h := range hunks {
+			changed[i] = h.oldRange()
+		}
+		if rangesTouched(lines, changed) {
+			return describeCommit(top, next)
+		}
+		lines = shiftRanges(lines, hunks)
+		prev = next
+	}
+	return nil, nil
+}
+
+// describeCommit returns the author and message of a commit.
+func describeCommit(top, commit string) (*Change, error) {
+	cmd := exec.Command("git", "show", "-s", "--format=%H%x00%an%x00%ae%x00%aI%x00%B", "--end-of-options", commit)
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(string(out), "\x00", 5)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected output from git show for %s", commit)
+	}
+	date, err := time.Parse(time.RFC3339, fields[3])
+	if err != nil {
+		return nil, err
+	}
+	return &Change{
+		Commit:  fields[0],
+		Author:  fields[1],
+		Email:   fields[2],
+		Date:    date,
+		Message: strings.TrimSpace(fields[4]),
+	}, nil
+}
+
+// rootCommits holds the root commit RepoIdentity found for each checkout, by
+// top-level directory. Finding it walks the whole history, and it does not
+// change.
+var rootCommits sync.Map
+
+// RepoIdentity identifies a repository by its root commit, which every clone
+// shares. Histories with several roots use the smallest hash.
+func (c *ExecGitChecker) RepoIdentity(filePath string) (string, string, error) {
+	top, rel, err := repoPath(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	if root, ok := rootCommits.Load(top); ok {
+		return root.(string), rel, nil
+	}
+	cmd := exec.Command("git", "rev-list", "--max-parents=0", "HEAD")
+	cmd.Dir = top
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", err
+	}
+	roots := strings.Fields(string(out))
+	if len(roots) == 0 {
+		return "", "", fmt.Errorf("no root commit in %s", top)
+	}
+	sort.Strings(roots)
+	rootCommits.Store(top, roots[0])
+	return roots[0], rel, nil
+}
+
+// DefaultBranch returns the remote's default branch, such as origin/main,
+// falling back to the branch checked out when there is no remote.
+func (c *ExecGitChecker) DefaultBranch(filePath string) (string, error) {
+	top, _, err := repoPath(filePath)
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range []string{"refs/remotes/origin/HEAD", "HEAD"} {
+		cmd := exec.Command("git", "symbolic-ref", "--quiet", "--short", ref)
+		cmd.Dir = top
+		if out, err := cmd.Output(); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", fmt.Errorf("no default branch in %s", top)
+}
+
+// repoPath returns the top-level directory of the repository containing
+// filePath, which may be a file or a directory, and its path relative to
+// that. It starts from the nearest existing directory, since the file and
+// its directory may have been moved away.
+func repoPath(filePath string) (top, rel string, err error) {
+	dir := filepath.Dir(filePath)
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		dir = filePath
+	}
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", err
+	}
+	top = strings.TrimSpace(string(out))
+
+	// git reports the top level with symlinks resolved, so resolve the
+	// existing part of the file's path the same way before relating them.
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", err
+	}
+	rest, err := filepath.Rel(dir, filePath)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err = filepath.Rel(top, filepath.Join(realDir, rest))
+	if err != nil {
+		return "", "", err
+	}
+	return top, filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecGitCheckerDoesNotTakeRevisionsAsOptions(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n")
+	commit := r.commit("add a")
+	out := filepath.Join(t.TempDir(), "written")
+	option := "--output=" + out
+
+	c := &ExecGitChecker{}
+	if _, err := c.HasFileChangedSince(option, r.path("a.go")); err == nil {
+		t.Errorf("expected an error for an option given as a commit")
+	}
+	c.CurrentPath(option, r.path("a.go"))
+	c.DeletingCommit(option, r.path("a.go"))
+	c.FirstChange(option, r.path("a.go"), []LineRange{{Start: 1, End: 1}})
+	c.IsAncestor(option, commit, r.path("a.go"))
+	c.ReadFileAt(option, r.path("a.go"))
+	(&ExecGitChecker{Ref: option}).UncommittedLines(r.path("a.go"))
+	(&ExecGitChecker{Ref: option}).HeadCommit(r.path("a.go"))
+
+	if _, err := os.Stat(out); err == nil {
+		t.Errorf("expected git not to write %s", out)
+	}
+}
+
+func TestExecGitCheckerReadsFilesFromRemovedDirectories(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("old/a.go", "package a\n\nfunc A() {}\n")
+	first := r.commit("add a")
+	r.git("mv", "old", "new")
+	r.commit("move a")
+	if _, err := os.Stat(r.path("old")); !os.IsNotExist(err) {
+		t.Fatalf("expected the old directory removed, got %v", err)
+	}
+
+	c := &ExecGitChecker{}
+	if data, err := c.ReadFileAt(first, r.path("old/a.go")); err != nil || string(data) != "package a\n\nfunc A() {}\n" {
+		t.Fatalf("expected the moved file's old contents, got %q (%v)", data, err)
+	}
+
+	g := New()
+	ev := g.AddEvidence(r.path("old/a.go"), "3", first)
+	v, err := g.EvidenceValidity(ev.ID, c, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid || v.CurrentPath != r.path("new/a.go") {
+		t.Errorf("expected valid evidence now at %s, got %+v", r.path("new/a.go"), v)
+	}
+	snippet, err := g.GetSnippet(ev.ID, c)
+	if err != nil || len(snippet.AtCommit) != 1 || len(snippet.AtHead) != 1 {
+		t.Errorf("expected the cited line at both commits, got %+v (%v)", snippet, err)
+	}
+}
+
+func TestExecGitCheckerRepoIdentityIsKept(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n")
+	root := r.commit("add a")
+
+	c := &ExecGitChecker{}
+	if repo, rel, err := c.RepoIdentity(r.path("a.go")); err != nil || repo != root || rel != "a.go" {
+		t.Fatalf("expected %s a.go, got %s %s (%v)", root, repo, rel, err)
+	}
+	// A new root is not looked for again: a repository keeps its identity.
+	r.git("checkout", "-q", "--orphan", "other")
+	r.commit("new root")
+	if repo, _, _ := c.RepoIdentity(r.path("a.go")); repo != root {
+		t.Errorf("expected the identity found first, %s, got %s", root, repo)
+	}
+}
+
+func TestExecGitCheckerUncommittedLinesAgainstHead(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n")
+	r.commit("add a")
+	r.git("branch", "base")
+	r.write("a.go", "package a\n\nfunc A() {}\n")
+	r.commit("add A")
+	r.write("a.go", "package a\n\nfunc A() {}\n\nfunc B() {}\n")
+
+	// Lines committed on the branch are not local edits, whatever ref the
+	// checker is scoped to.
+	for _, c := range []*ExecGitChecker{{}, {Ref: "base"}} {
+		lines, err := c.UncommittedLines(r.path("a.go"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(lines) != 1 || lines[0] != (LineRange{Start: 4, End: 3}) {
+			t.Errorf("ref %q: expected an insertion after line 3, got %v", c.Ref, lines)
+		}
+	}
+}
@@ -0,0 +1,517 @@
+package graph
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NativeGitChecker implements GitChecker by reading repositories directly
+// from their .git directories, without a git binary. It supports the same
+// optional capabilities as ExecGitChecker except WorkingTreeChecker and
+// LineBlamer, and follows only renames that keep at least half of a file's
+// lines. Create it with NewNativeGitChecker.
+type NativeGitChecker struct {
+	// Ref is checked against in place of HEAD when set.
+	Ref string
+
+	repos *nativeRepos
+}
+
+// nativeRepos caches opened repositories by git directory.
+type nativeRepos struct {
+	mu    sync.Mutex
+	repos map[string]*gitRepo
+}
+
+// NewNativeGitChecker returns a checker that caches repositories and parsed
+// commits across checks.
+func NewNativeGitChecker() *NativeGitChecker {
+	return &NativeGitChecker{repos: &nativeRepos{repos: make(map[string]*gitRepo)}}
+}
+
+func (c *NativeGitChecker) AtRef(ref string) GitChecker {
+	return &NativeGitChecker{Ref: ref, repos: c.repos}
+}
+
+func (c *NativeGitChecker) head() string {
+	if c.Ref != "" {
+		return c.Ref
+	}
+	return "HEAD"
+}
+
+// open returns the repository containing filePath along with its top-level
+// directory and the path relative to it.
+func (c *NativeGitChecker) open(filePath string) (repo *gitRepo, top, rel string, err error) {
+	top, rel, gitDir, err := findGitRepo(filePath)
+	if err != nil {
+		return nil, "", "", err
+	}
+	c.repos.mu.Lock()
+	defer c.repos.mu.Unlock()
+	repo, ok := c.repos.repos[gitDir]
+	if !ok {
+		if repo, err = openGitRepo(gitDir); err != nil {
+			return nil, "", "", err
+		}
+		c.repos.repos[gitDir] = repo
+	}
+	return repo, top, rel, nil
+}
+
+// headCommit resolves the revision the checker treats as HEAD.
+func (c *NativeGitChecker) headCommit(repo *gitRepo, top string) (string, error) {
+	hash, err := repo.resolveCommit(c.head())
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q in %s", c.head(), top)
+	}
+	return hash, nil
+}
+
+func (c *NativeGitChecker) HasFileChangedSince(commit, filePath string) (bool, error) {
+	repo, top, rel, err := c.open(filePath)
+	if err != nil {
+		return false, err
+	}
+	base, err := repo.resolveCommit(commit)
+	if err != nil {
+		return false, err
+	}
+	head, err := c.headCommit(repo, top)
+	if err != nil {
+		return false, err
+	}
+	touching, err := repo.commitsTouching(base, head, rel, true)
+	return len(touching) > 0, err
+}
+
+func (c *NativeGitChecker) ReadFileAt(rev, filePath string) ([]byte, error) {
+	repo, top, rel, err := c.open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if rev == "HEAD" {
+		rev = c.head()
+	}
+	hash, err := repo.resolveCommit(rev)
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q in %s", rev, top)
+	}
+	blob, err := repo.blobAt(hash, rel)
+	if err != nil {
+		return nil, err
+	}
+	if blob == "" {
+		return nil, fmt.Errorf("path %q does not exist in %q", rel, rev)
+	}
+	return repo.readTypedObject(blob, "blob")
+}
+
+// CurrentPath follows a file deleted from its path since commit to the file
+// added at HEAD that best matches it, the way git diff -M does.
+func (c *NativeGitChecker) CurrentPath(commit, filePath string) (string, error) {
+	repo, top, rel, err := c.open(filePath)
+	if err != nil {
+		return "", err
+	}
+	base, err := repo.resolveCommit(commit)
+	if err != nil {
+		return "", err
+	}
+	head, err := c.headCommit(repo, top)
+	if err != nil {
+		return "", err
+	}
+	if blob, err := repo.blobAt(head, rel); err != nil || blob != "" {
+		return filePath, err
+	}
+	oldBlob, err := repo.blobAt(base, rel)
+	if err != nil || oldBlob == "" {
+		return filePath, err
+	}
+
+	added, err := repo.addedFiles(base, head)
+	if err != nil {
+		return "", err
+	}
+	best, bestScore := "", 0.0
+	var old []byte
+	for _, path := range added.paths {
+		if added.blobs[path] == oldBlob {
+			best = path
+			break
+		}
+		if old == nil {
+			if old, err = repo.readTypedObject(oldBlob, "blob"); err != nil {
+				return "", err
+			}
+		}
+		data, err := repo.readTypedObject(added.blobs[path], "blob")
+		if err != nil {
+			return "", err
+		}
+		if score := similarity(old, data); score >= 0.5 && score > bestScore {
+			best, bestScore = path, score
+		}
+	}
+	if best == "" {
+		return filePath, nil
+	}
+	return filepath.Join(top, filepath.FromSlash(best)), nil
+}
+
+func (c *NativeGitChecker) DeletingCommit(commit, filePath string) (string, error) {
+	repo, top, rel, err := c.open(filePath)
+	if err != nil {
+		return "", err
+	}
+	head, err := c.headCommit(repo, top)
+	if err != nil {
+		return "", err
+	}
+	if blob, err := repo.blobAt(head, rel); err != nil || blob != "" {
+		return "", err
+	}
+	base, err := repo.resolveCommit(commit)
+	if err != nil {
+		return "", err
+	}
+	touching, err := repo.commitsTouching(base, head, rel, false)
+	if err != nil {
+		return "", err
+	}
+	var deleting *gitCommit
+	for _, t := range touching {
+		blob, err := repo.blobAt(t.hash, rel)
+		if err != nil {
+			return "", err
+		}
+		if blob == "" && (deleting == nil || t.time > deleting.time) {
+			deleting = t
+		}
+	}
+	if deleting == nil {
+		return "", nil
+	}
+	return deleting.hash, nil
+}
+
+func (c *NativeGitChecker) HeadCommit(filePath string) (string, error) {
+	repo, top, _, err := c.open(filePath)
+	if err != nil {
+		return "", err
+	}
+	return c.headCommit(repo, top)
+}
+
+func (c *NativeGitChecker) IsAncestor(ancestor, commit, filePath string) (bool, error) {
+	repo, top, _, err := c.open(filePath)
+	if err != nil {
+		return false, err
+	}
+	a, err := repo.resolveCommit(ancestor)
+	if err != nil {
+		return false, fmt.Errorf("unknown revision %q in %s", ancestor, top)
+	}
+	d, err := repo.resolveCommit(commit)
+	if err != nil {
+		return false, fmt.Errorf("unknown revision %q in %s", commit, top)
+	}
+	// ancestor is reachable from commit exactly when the range
+	// commit..ancestor is empty.
+	isAncestor := true
+	err = repo.walkRange(d, a, func(*gitCommit) (bool, error) {
+		isAncestor = false
+		return false, nil
+	})
+	return isAncestor, err
+}
+
+// RepoIdentity identifies a repository by its root commit, like
+// ExecGitChecker. The root is found once per repository, since it walks the
+// whole history.
+func (c *NativeGitChecker) RepoIdentity(filePath string) (string, string, error) {
+	repo, top, rel, err := c.open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	repo.mu.Lock()
+	root := repo.root
+	repo.mu.Unlock()
+	if root != "" {
+		return root, rel, nil
+	}
+	head, err := repo.resolveCommit("HEAD")
+	if err != nil {
+		return "", "", fmt.Errorf("no root commit in %s", top)
+	}
+	var roots []string
+	err = repo.walk(head, func(commit *gitCommit) (bool, error) {
+		if len(commit.parents) == 0 {
+			roots = append(roots, commit.hash)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	sort.Strings(roots)
+	repo.mu.Lock()
+	repo.root = roots[0]
+	repo.mu.Unlock()
+	return roots[0], rel, nil
+}
+
+// DefaultBranch returns the remote's default branch, such as origin/main,
+// falling back to the branch checked out.
+func (c *NativeGitChecker) DefaultBranch(filePath string) (string, error) {
+	repo, top, _, err := c.open(filePath)
+	if err != nil {
+		return "", err
+	}
+	if ref, ok := repo.symbolicRef("refs/remotes/origin/HEAD"); ok {
+		return strings.TrimPrefix(ref, "refs/remotes/"), nil
+	}
+	if ref, ok := repo.symbolicRef("HEAD"); ok {
+		return strings.TrimPrefix(ref, "refs/heads/"), nil
+	}
+	return "", fmt.Errorf("no default branch in %s", top)
+}
+
+// walk visits the commits reachable from start, following parents while
+// visit returns true.
+func (r *gitRepo) walk(start string, visit func(*gitCommit) (bool, error)) error {
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		commit, err := r.commit(hash)
+		if err != nil {
+			return err
+		}
+		more, err := visit(commit)
+		if err != nil || !more {
+			return err
+		}
+		for _, p := range commit.parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return nil
+}
+
+// commitsTouching returns the commits in base..head that change the file at
+// path, in the sense of git log base..head -- path: commits that differ at
+// path from every parent. With first set, it stops at the first one found.
+func (r *gitRepo) commitsTouching(base, head, path string, first bool) ([]*gitCommit, error) {
+	var touching []*gitCommit
+	err := r.walkRange(base, head, func(commit *gitCommit) (bool, error) {
+		touches, err := r.touches(commit, path)
+		if err != nil {
+			return false, err
+		}
+		if touches {
+			touching = append(touching, commit)
+		}
+		return !(first && touches), nil
+	})
+	return touching, err
+}
+
+// walkRange visits the commits reachable from head but not from base, the
+// commits git log base..head lists, newest first, while visit returns true.
+// Like git, it first walks back from both commits at once in committer time
+// order, marking what base reaches, and stops once no commit left to look at
+// can be in the range or reach one that was taken to be; its cost therefore
+// follows the size of the range rather than of the whole history. As in
+// git, commit times are trusted to be roughly ordered.
+func (r *gitRepo) walkRange(base, head string, visit func(*gitCommit) (bool, error)) error {
+	seen := map[string]*gitCommit{}
+	hidden := map[string]bool{} // reachable from base
+	popped := map[string]bool{}
+	pending := 0 // queued commits not known to be reachable from base
+	queue := &commitQueue{}
+
+	// hide marks a seen commit as reachable from base, along with the seen
+	// commits it reaches, which were walked as if they were not.
+	hide := func(hash string) {
+		stack := []string{hash}
+		for len(stack) > 0 {
+			h := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if hidden[h] || seen[h] == nil {
+				continue
+			}
+			hidden[h] = true
+			if !popped[h] {
+				pending--
+				continue
+			}
+			stack = append(stack, seen[h].parents...)
+		}
+	}
+	push := func(hash string, fromBase bool) error {
+		if seen[hash] != nil {
+			if fromBase {
+				hide(hash)
+			}
+			return nil
+		}
+		commit, err := r.commit(hash)
+		if err != nil {
+			return err
+		}
+		seen[hash], hidden[hash] = commit, fromBase
+		if !fromBase {
+			pending++
+		}
+		heap.Push(queue, commit)
+		return nil
+	}
+	if err := push(head, false); err != nil {
+		return err
+	}
+	if err := push(base, true); err != nil {
+		return err
+	}
+
+	var candidates []*gitCommit
+	oldest := int64(math.MaxInt64)
+	for queue.Len() > 0 {
+		// A commit older than every candidate cannot reach one.
+		if pending == 0 && (*queue)[0].time < oldest {
+			break
+		}
+		commit := heap.Pop(queue).(*gitCommit)
+		popped[commit.hash] = true
+		fromBase := hidden[commit.hash]
+		if !fromBase {
+			pending--
+			candidates = append(candidates, commit)
+			oldest = min(oldest, commit.time)
+		}
+		for _, p := range commit.parents {
+			if err := push(p, fromBase); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, commit := range candidates {
+		if hidden[commit.hash] {
+			continue
+		}
+		more, err := visit(commit)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitQueue is a heap of commits, newest first by committer time.
+type commitQueue []*gitCommit
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	if q[i].time != q[j].time {
+		return q[i].time > q[j].time
+	}
+	return q[i].hash < q[j].hash
+}
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(*gitCommit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+func (r *gitRepo) touches(commit *gitCommit, path string) (bool, error) {
+	blob, err := r.blobAt(commit.hash, path)
+	if err != nil {
+		return false, err
+	}
+	if len(commit.parents) == 0 {
+		return blob != "", nil
+	}
+	for _, p := range commit.parents {
+		parentBlob, err := r.blobAt(p, path)
+		if err != nil {
+			return false, err
+		}
+		if parentBlob == blob {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// addedFileSet lists files present at one commit but not another.
+type addedFileSet struct {
+	paths []string          // sorted
+	blobs map[string]string // path -> blob hash
+}
+
+// addedFiles returns the files at head whose paths do not exist at base,
+// skipping directories that are identical in both.
+func (r *gitRepo) addedFiles(base, head string) (*addedFileSet, error) {
+	baseCommit, err := r.commit(base)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := r.commit(head)
+	if err != nil {
+		return nil, err
+	}
+	baseDirs := make(map[string]string)
+	if err := r.subtrees(baseCommit.tree, "", baseDirs); err != nil {
+		return nil, err
+	}
+	headFiles := make(map[string]string)
+	if err := r.listFiles(headCommit.tree, "", baseDirs, headFiles); err != nil {
+		return nil, err
+	}
+	added := &addedFileSet{blobs: make(map[string]string)}
+	for path, blob := range headFiles {
+		if existing, err := r.pathEntry(baseCommit.tree, path); err != nil {
+			return nil, err
+		} else if existing.hash == "" {
+			added.paths = append(added.paths, path)
+			added.blobs[path] = blob
+		}
+	}
+	sort.Strings(added.paths)
+	return added, nil
+}
+
+// similarity scores how much of a survives in b, as the share of bytes in
+// lines the two have in common, relative to the larger of the two.
+func similarity(a, b []byte) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	counts := make(map[string]int)
+	for _, line := range bytes.SplitAfter(a, []byte("\n")) {
+		counts[string(line)]++
+	}
+	common := 0
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if counts[string(line)] > 0 {
+			counts[string(line)]--
+			common += len(line)
+		}
+	}
+	return float64(common) / float64(max(len(a), len(b)))
+}
@@ -0,0 +1,296 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// gitTestRepo builds a repository with the git binary, for comparing
+// NativeGitChecker against ExecGitChecker.
+type gitTestRepo struct {
+	t   testing.TB
+	dir string
+}
+
+func newGitTestRepo(t testing.TB) *gitTestRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	r := &gitTestRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	return r
+}
+
+func (r *gitTestRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=A", "GIT_AUTHOR_EMAIL=a@example.com",
+		"GIT_COMMITTER_NAME=A", "GIT_COMMITTER_EMAIL=a@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *gitTestRepo) write(path, content string) {
+	r.t.Helper()
+	full := filepath.Join(r.dir, path)
+	os.MkdirAll(filepath.Dir(full), 0755)
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *gitTestRepo) commit(msg string) string {
+	r.git("add", "-A")
+	r.git("commit", "-q", "-m", msg)
+	return r.git("rev-parse", "HEAD")
+}
+
+func (r *gitTestRepo) path(rel string) string {
+	return filepath.Join(r.dir, rel)
+}
+
+// longSource returns a file long enough for git to store later versions as
+// deltas against it.
+func longSource(n int, extra string) string {
+	var b strings.Builder
+	b.WriteString("package demo\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "func F%d() int {\n\treturn %d\n}\n\n", i, i)
+	}
+	b.WriteString(extra)
+	return b.String()
+}
+
+func TestNativeGitCheckerMatchesExec(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", longSource(40, ""))
+	r.write("pkg/b.go", "package pkg\n")
+	r.write("gone.go", "package demo\n")
+	first := r.commit("first")
+	r.git("tag", "-a", "v1", "-m", "v1")
+
+	r.write("a.go", longSource(40, "// edited\n"))
+	r.commit("edit a")
+	r.git("mv", "pkg/b.go", "pkg/moved.go")
+	r.write("pkg/moved.go", "package pkg\n\nfunc B() {}\n")
+	r.commit("move b")
+	r.git("rm", "-q", "gone.go")
+	r.commit("delete gone")
+	r.git("branch", "old", first)
+
+	for _, stage := range []string{"loose", "packed"} {
+		if stage == "packed" {
+			r.git("gc", "-q", "--aggressive")
+			if _, err := os.Stat(filepath.Join(r.dir, ".git", "packed-refs")); err != nil {
+				t.Fatal("expected git gc to pack refs")
+			}
+		}
+		native := NewNativeGitChecker()
+		execChecker := &ExecGitChecker{}
+
+		for _, p := range []string{"a.go", "pkg/b.go", "gone.go"} {
+			want, _ := execChecker.HasFileChangedSince(first, r.path(p))
+			got, err := native.HasFileChangedSince(first, r.path(p))
+			if err != nil || got != want {
+				t.Errorf("%s: HasFileChangedSince(%s): expected %v, got %v (%v)", stage, p, want, got, err)
+			}
+
+			wantPath, _ := execChecker.CurrentPath(first, r.path(p))
+			gotPath, err := native.CurrentPath(first, r.path(p))
+			if err != nil || gotPath != wantPath {
+				t.Errorf("%s: CurrentPath(%s): expected %q, got %q (%v)", stage, p, wantPath, gotPath, err)
+			}
+
+			wantDel, _ := execChecker.DeletingCommit(first, r.path(p))
+			gotDel, err := native.DeletingCommit(first, r.path(p))
+			if err != nil || gotDel != wantDel {
+				t.Errorf("%s: DeletingCommit(%s): expected %q, got %q (%v)", stage, p, wantDel, gotDel, err)
+			}
+		}
+
+		for _, rev := range []string{first, first[:7], "HEAD", "v1", "old", "main", "refs/heads/main"} {
+			want, _ := execChecker.ReadFileAt(rev, r.path("a.go"))
+			got, err := native.ReadFileAt(rev, r.path("a.go"))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("%s: ReadFileAt(%s): contents differ (%v)", stage, rev, err)
+			}
+		}
+
+		for _, ref := range []string{"", "v1", "old"} {
+			want, _ := execChecker.AtRef(ref).(HeadResolver).HeadCommit(r.path("a.go"))
+			got, err := native.AtRef(ref).(HeadResolver).HeadCommit(r.path("a.go"))
+			if err != nil || got != want {
+				t.Errorf("%s: HeadCommit at %q: expected %q, got %q (%v)", stage, ref, want, got, err)
+			}
+		}
+		if _, err := native.AtRef("nope").(HeadResolver).HeadCommit(r.path("a.go")); err == nil {
+			t.Errorf("%s: expected error for unknown ref", stage)
+		}
+
+		head := r.git("rev-parse", "HEAD")
+		for _, pair := range [][2]string{{first, head}, {head, first}, {first, first}, {"old", "v1"}} {
+			want, _ := execChecker.IsAncestor(pair[0], pair[1], r.path("a.go"))
+			got, err := native.IsAncestor(pair[0], pair[1], r.path("a.go"))
+			if err != nil || got != want {
+				t.Errorf("%s: IsAncestor(%s, %s): expected %v, got %v (%v)", stage, pair[0], pair[1], want, got, err)
+			}
+		}
+		if _, err := native.IsAncestor("nope", head, r.path("a.go")); err == nil {
+			t.Errorf("%s: expected error for unknown revision", stage)
+		}
+
+		wantRepo, wantRel, _ := execChecker.RepoIdentity(r.path("pkg/moved.go"))
+		gotRepo, gotRel, err := native.RepoIdentity(r.path("pkg/moved.go"))
+		if err != nil || gotRepo != wantRepo || gotRel != wantRel {
+			t.Errorf("%s: RepoIdentity: expected %s %s, got %s %s (%v)", stage, wantRepo, wantRel, gotRepo, gotRel, err)
+		}
+
+		wantBranch, _ := execChecker.DefaultBranch(r.path("a.go"))
+		gotBranch, err := native.DefaultBranch(r.path("a.go"))
+		if err != nil || gotBranch != wantBranch {
+			t.Errorf("%s: DefaultBranch: expected %q, got %q (%v)", stage, wantBranch, gotBranch, err)
+		}
+	}
+}
+
+func TestWalkRangeMatchesRevList(t *testing.T) {
+	r := newGitTestRepo(t)
+	var commits []string
+	for i := 0; i < 3; i++ {
+		r.write("a.go", fmt.Sprintf("package demo // %d\n", i))
+		commits = append(commits, r.commit("main"))
+	}
+	r.git("checkout", "-q", "-b", "side", commits[0])
+	for i := 0; i < 3; i++ {
+		r.write("b.go", fmt.Sprintf("package demo // %d\n", i))
+		commits = append(commits, r.commit("side"))
+	}
+	r.git("checkout", "-q", "main")
+	r.git("merge", "-q", "--no-edit", "side")
+	commits = append(commits, r.git("rev-parse", "HEAD"))
+	r.write("a.go", "package demo // merged\n")
+	commits = append(commits, r.commit("after merge"))
+
+	native := NewNativeGitChecker()
+	repo, _, _, err := native.open(r.path("a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, base := range commits {
+		for _, head := range commits {
+			want := strings.Fields(r.git("rev-list", base+".."+head))
+			var got []string
+			err := repo.walkRange(base, head, func(c *gitCommit) (bool, error) {
+				got = append(got, c.hash)
+				return true, nil
+			})
+			sort.Strings(want)
+			sort.Strings(got)
+			if err != nil || strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("%s..%s: expected %v, got %v (%v)", base[:7], head[:7], want, got, err)
+			}
+		}
+	}
+}
+
+// BenchmarkHasFileChangedSince checks a file last changed early in a long
+// history, so each check covers every later commit.
+func BenchmarkHasFileChangedSince(b *testing.B) {
+	r := newGitTestRepo(b)
+	r.write("cited.go", longSource(40, ""))
+	r.write("pkg/busy.go", "package pkg\n")
+	first := r.commit("first")
+	for i := 0; i < 200; i++ {
+		r.write("pkg/busy.go", fmt.Sprintf("package pkg\n\nconst N = %d\n", i))
+		r.commit("busy")
+	}
+	r.git("gc", "-q")
+
+	checkers := []struct {
+		name    string
+		checker GitChecker
+	}{
+		{"native", NewNativeGitChecker()},
+		{"exec", &ExecGitChecker{}},
+	}
+	for _, c := range checkers {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if changed, err := c.checker.HasFileChangedSince(first, r.path("cited.go")); err != nil || changed {
+					b.Fatalf("expected no change, got %v (%v)", changed, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNativeGitCheckerValidity(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package demo\n\nfunc A() int {\n\treturn 1\n}\n")
+	first := r.commit("first")
+	r.write("a.go", "package demo\n\n// A returns one.\nfunc A() int {\n\treturn 1\n}\n")
+	r.commit("comment")
+	r.git("gc", "-q")
+
+	g := New()
+	ev := g.AddEvidence(r.path("a.go"), "3-5", first)
+	ev.Symbol = "A"
+
+	v, err := g.EvidenceValidity(ev.ID, NewNativeGitChecker(), CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid || v.CurrentLineRef != "4-6" {
+		t.Errorf("expected symbol evidence valid at 4-6, got %+v", v)
+	}
+}
+
+func TestNativeGitCheckerRepoIdentityIsKept(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n")
+	root := r.commit("add a")
+
+	c := NewNativeGitChecker()
+	if repo, _, err := c.RepoIdentity(r.path("a.go")); err != nil || repo != root {
+		t.Fatalf("expected %s, got %s (%v)", root, repo, err)
+	}
+	r.git("checkout", "-q", "--orphan", "other")
+	r.commit("new root")
+	if repo, _, _ := c.RepoIdentity(r.path("a.go")); repo != root {
+		t.Errorf("expected the identity found first, %s, got %s", root, repo)
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello, world\n")
+	// Source size 13, target size 12, copy 7 bytes from offset 0, then
+	// insert "there".
+	delta := []byte{13, 12, 0x80 | 0x01 | 0x10, 0, 7, 5, 't', 'h', 'e', 'r', 'e'}
+
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "hello, there" {
+		t.Errorf("expected %q, got %q", "hello, there", got)
+	}
+
+	if _, err := applyDelta([]byte("short"), delta); err == nil {
+		t.Error("expected error for mismatched base size")
+	}
+	if _, err := applyDelta(base, delta[:len(delta)-2]); err == nil {
+		t.Error("expected error for truncated delta")
+	}
+}
@@ -0,0 +1,830 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gitRepo reads objects and refs straight from a repository's .git
+// directory. It caches parsed commits and trees and the pack indexes, and is
+// safe for concurrent use.
+type gitRepo struct {
+	gitDir    string // the worktree's git directory, holding HEAD
+	commonDir string // objects, refs and packed-refs
+
+	mu      sync.Mutex
+	packs   []*packFile
+	commits map[string]*gitCommit
+	trees   map[string][]gitTreeEntry
+	shallow map[string]bool
+	root    string // the root commit RepoIdentity found, once it has
+}
+
+// maxCachedTrees bounds the parsed trees a gitRepo keeps. Looking a path up
+// reads a tree per directory for every commit walked, and each may have to be
+// rebuilt from a chain of deltas, so trees are worth keeping; once this many
+// are cached the cache starts over.
+const maxCachedTrees = 10000
+
+// gitCommit is the part of a commit object needed to walk history.
+type gitCommit struct {
+	hash    string
+	tree    string
+	parents []string
+	time    int64 // committer timestamp
+}
+
+// gitTreeEntry is one entry of a tree object.
+type gitTreeEntry struct {
+	mode string
+	name string
+	hash string
+}
+
+func (e gitTreeEntry) isTree() bool {
+	return e.mode == "40000"
+}
+
+// findGitRepo locates the repository containing filePath, which may be a
+// file or a directory and need not exist. It returns the top-level directory
+// of the worktree, the path's slash-separated location relative to it, and
+// the worktree's git directory.
+func findGitRepo(filePath string) (top, rel, gitDir string, err error) {
+	dir := filepath.Dir(filePath)
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		dir = filePath
+	}
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", "", err
+	}
+	rest, err := filepath.Rel(dir, filePath)
+	if err != nil {
+		return "", "", "", err
+	}
+	target := filepath.Join(realDir, rest)
+
+	for top = realDir; ; top = filepath.Dir(top) {
+		dotGit := filepath.Join(top, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				gitDir = dotGit
+			} else if gitDir, err = readGitFile(dotGit); err != nil {
+				return "", "", "", err
+			}
+			break
+		}
+		if filepath.Dir(top) == top {
+			return "", "", "", fmt.Errorf("%s is not in a git repository", filePath)
+		}
+	}
+	if rel, err = filepath.Rel(top, target); err != nil {
+		return "", "", "", err
+	}
+	return top, filepath.ToSlash(rel), gitDir, nil
+}
+
+// readGitFile follows a .git file, as used by worktrees and submodules, to
+// the git directory it names.
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("%s: not a gitdir file", path)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	return target, nil
+}
+
+func openGitRepo(gitDir string) (*gitRepo, error) {
+	r := &gitRepo{gitDir: gitDir, commonDir: gitDir, commits: make(map[string]*gitCommit), trees: make(map[string][]gitTreeEntry)}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		r.commonDir = common
+	}
+	if _, err := os.Stat(filepath.Join(r.commonDir, "objects")); err != nil {
+		return nil, fmt.Errorf("%s is not a git directory", gitDir)
+	}
+	r.shallow = make(map[string]bool)
+	if data, err := os.ReadFile(filepath.Join(r.commonDir, "shallow")); err == nil {
+		for _, h := range strings.Fields(string(data)) {
+			r.shallow[h] = true
+		}
+	}
+	if err := r.loadPacks(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadPacks opens pack indexes that are new since the last call and drops
+// those that have been removed. Callers hold mu or own r.
+func (r *gitRepo) loadPacks() error {
+	idxFiles, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(idxFiles)
+	open := make(map[string]*packFile, len(r.packs))
+	for _, p := range r.packs {
+		open[p.idxPath] = p
+	}
+	packs := make([]*packFile, 0, len(idxFiles))
+	for _, idx := range idxFiles {
+		p, ok := open[idx]
+		if ok {
+			delete(open, idx)
+		} else if p, err = openPackFile(idx); err != nil {
+			return err
+		}
+		packs = append(packs, p)
+	}
+	for _, p := range open {
+		p.close()
+	}
+	r.packs = packs
+	return nil
+}
+
+// readObject returns the type and contents of the object with the given
+// full hash.
+func (r *gitRepo) readObject(hash string) (string, []byte, error) {
+	if len(hash) != 40 {
+		return "", nil, fmt.Errorf("invalid object name %q", hash)
+	}
+	typ, data, err := r.readLooseObject(hash)
+	if err == nil || !os.IsNotExist(err) {
+		return typ, data, err
+	}
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid object name %q", hash)
+	}
+
+	r.mu.Lock()
+	packs := r.packs
+	r.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		for _, p := range packs {
+			if offset, ok := p.find(raw); ok {
+				return p.readAt(r, offset)
+			}
+		}
+		if attempt > 0 {
+			break
+		}
+		// The object may have been packed since the indexes were read.
+		r.mu.Lock()
+		err := r.loadPacks()
+		packs = r.packs
+		r.mu.Unlock()
+		if err != nil {
+			return "", nil, err
+		}
+		if typ, data, err := r.readLooseObject(hash); err == nil {
+			return typ, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("object %s not found", hash)
+}
+
+func (r *gitRepo) readLooseObject(hash string) (string, []byte, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "objects", hash[:2], hash[2:]))
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %v", hash, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %v", hash, err)
+	}
+	header, body, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", nil, fmt.Errorf("object %s: malformed header", hash)
+	}
+	typ, sizeStr, _ := strings.Cut(string(header), " ")
+	if size, err := strconv.Atoi(sizeStr); err != nil || size != len(body) {
+		return "", nil, fmt.Errorf("object %s: size mismatch", hash)
+	}
+	return typ, body, nil
+}
+
+// readTypedObject reads an object and checks its type.
+func (r *gitRepo) readTypedObject(hash, want string) ([]byte, error) {
+	typ, data, err := r.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if typ != want {
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, typ, want)
+	}
+	return data, nil
+}
+
+// commit returns the parsed commit with the given full hash.
+func (r *gitRepo) commit(hash string) (*gitCommit, error) {
+	r.mu.Lock()
+	c, ok := r.commits[hash]
+	r.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	data, err := r.readTypedObject(hash, "commit")
+	if err != nil {
+		return nil, err
+	}
+	c = &gitCommit{hash: hash}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.tree = value
+		case "parent":
+			c.parents = append(c.parents, value)
+		case "committer":
+			fields := strings.Fields(value)
+			if len(fields) >= 2 {
+				c.time, _ = strconv.ParseInt(fields[len(fields)-2], 10, 64)
+			}
+		}
+	}
+	// A shallow clone has no history past its boundary commits.
+	if r.shallow[hash] {
+		c.parents = nil
+	}
+	r.mu.Lock()
+	r.commits[hash] = c
+	r.mu.Unlock()
+	return c, nil
+}
+
+// tree returns the parsed entries of the tree with the given full hash. The
+// entries are shared and must not be modified.
+func (r *gitRepo) tree(hash string) ([]gitTreeEntry, error) {
+	r.mu.Lock()
+	entries, ok := r.trees[hash]
+	r.mu.Unlock()
+	if ok {
+		return entries, nil
+	}
+	entries, err := r.parseTree(hash)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if len(r.trees) >= maxCachedTrees {
+		r.trees = make(map[string][]gitTreeEntry)
+	}
+	r.trees[hash] = entries
+	r.mu.Unlock()
+	return entries, nil
+}
+
+func (r *gitRepo) parseTree(hash string) ([]gitTreeEntry, error) {
+	data, err := r.readTypedObject(hash, "tree")
+	if err != nil {
+		return nil, err
+	}
+	var entries []gitTreeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+21 {
+			return nil, fmt.Errorf("tree %s: malformed entry", hash)
+		}
+		entries = append(entries, gitTreeEntry{
+			mode: string(data[:sp]),
+			name: string(data[sp+1 : nul]),
+			hash: hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// pathEntry returns the entry at the slash-separated path within the tree,
+// or a zero entry if there is none.
+func (r *gitRepo) pathEntry(treeHash, path string) (gitTreeEntry, error) {
+	entry := gitTreeEntry{mode: "40000", hash: treeHash}
+	for _, name := range strings.Split(path, "/") {
+		if !entry.isTree() {
+			return gitTreeEntry{}, nil
+		}
+		entries, err := r.tree(entry.hash)
+		if err != nil {
+			return gitTreeEntry{}, err
+		}
+		entry = gitTreeEntry{}
+		for _, e := range entries {
+			if e.name == name {
+				entry = e
+				break
+			}
+		}
+		if entry.hash == "" {
+			return gitTreeEntry{}, nil
+		}
+	}
+	return entry, nil
+}
+
+// blobAt returns the hash of the file at path in a commit, or "" if the
+// commit has no file there.
+func (r *gitRepo) blobAt(commitHash, path string) (string, error) {
+	c, err := r.commit(commitHash)
+	if err != nil {
+		return "", err
+	}
+	entry, err := r.pathEntry(c.tree, path)
+	if err != nil || entry.isTree() {
+		return "", err
+	}
+	return entry.hash, nil
+}
+
+// listFiles returns the blob hash of every file under the tree, keyed by
+// slash-separated path. Subtrees whose hash is in skip map to that hash are
+// left out, so unchanged directories need not be read.
+func (r *gitRepo) listFiles(treeHash, prefix string, skip map[string]string, files map[string]string) error {
+	entries, err := r.tree(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := prefix + e.name
+		if e.isTree() {
+			if skip[path] == e.hash {
+				continue
+			}
+			if err := r.listFiles(e.hash, path+"/", skip, files); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.mode != "160000" {
+			files[path] = e.hash
+		}
+	}
+	return nil
+}
+
+// subtrees returns the hash of every directory under the tree, keyed by
+// slash-separated path.
+func (r *gitRepo) subtrees(treeHash, prefix string, dirs map[string]string) error {
+	entries, err := r.tree(treeHash)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.isTree() {
+			dirs[prefix+e.name] = e.hash
+			if err := r.subtrees(e.hash, prefix+e.name+"/", dirs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveCommit resolves a revision to the full hash of a commit. It accepts
+// full or abbreviated hashes, "HEAD", full ref names and the short names git
+// accepts for branches, tags and remote branches, each optionally followed
+// by "^{commit}". Annotated tags are peeled.
+func (r *gitRepo) resolveCommit(rev string) (string, error) {
+	name := strings.TrimSuffix(rev, "^{commit}")
+	hash, err := r.resolveName(name)
+	if err != nil {
+		return "", err
+	}
+	for range 10 {
+		typ, data, err := r.readObject(hash)
+		if err != nil {
+			return "", fmt.Errorf("unknown revision %q", rev)
+		}
+		switch typ {
+		case "commit":
+			return hash, nil
+		case "tag":
+			target, ok := strings.CutPrefix(string(data), "object ")
+			if !ok || len(target) < 40 {
+				return "", fmt.Errorf("tag %s: malformed", hash)
+			}
+			hash = target[:40]
+		default:
+			return "", fmt.Errorf("revision %q is a %s, not a commit", rev, typ)
+		}
+	}
+	return "", fmt.Errorf("revision %q: too many nested tags", rev)
+}
+
+func (r *gitRepo) resolveName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty revision")
+	}
+	if len(name) == 40 && isHex(name) {
+		return name, nil
+	}
+	candidates := []string{name}
+	if name != "HEAD" && !strings.HasPrefix(name, "refs/") {
+		candidates = append(candidates,
+			"refs/"+name,
+			"refs/tags/"+name,
+			"refs/heads/"+name,
+			"refs/remotes/"+name,
+			"refs/remotes/"+name+"/HEAD")
+	}
+	for _, ref := range candidates {
+		if hash, ok, err := r.readRef(ref, 0); err != nil {
+			return "", err
+		} else if ok {
+			return hash, nil
+		}
+	}
+	if len(name) >= 4 && isHex(name) {
+		return r.expandHash(strings.ToLower(name))
+	}
+	return "", fmt.Errorf("unknown revision %q", name)
+}
+
+// readRef reads a loose or packed ref, following symbolic refs. ok is false
+// if the ref does not exist.
+func (r *gitRepo) readRef(ref string, depth int) (hash string, ok bool, err error) {
+	if depth > 5 {
+		return "", false, fmt.Errorf("ref %s: too many levels of symbolic refs", ref)
+	}
+	target, symbolic, found := r.readLooseRef(ref)
+	if found {
+		if symbolic {
+			return r.readRef(target, depth+1)
+		}
+		return target, true, nil
+	}
+	hash, found = r.packedRefs()[ref]
+	return hash, found, nil
+}
+
+// readLooseRef reads a ref file. Refs private to a worktree, such as HEAD,
+// live in its git directory; the rest are shared.
+func (r *gitRepo) readLooseRef(ref string) (target string, symbolic, found bool) {
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err != nil {
+			continue
+		}
+		content := strings.TrimSpace(string(data))
+		if t, ok := strings.CutPrefix(content, "ref: "); ok {
+			return t, true, true
+		}
+		if len(content) == 40 && isHex(content) {
+			return content, false, true
+		}
+	}
+	return "", false, false
+}
+
+// symbolicRef returns the ref a symbolic ref such as HEAD points at.
+func (r *gitRepo) symbolicRef(ref string) (string, bool) {
+	target, symbolic, found := r.readLooseRef(ref)
+	return target, found && symbolic
+}
+
+func (r *gitRepo) packedRefs() map[string]string {
+	refs := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return refs
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hash, ref, ok := strings.Cut(line, " ")
+		if ok && len(hash) == 40 {
+			refs[ref] = hash
+		}
+	}
+	return refs
+}
+
+// expandHash finds the single object whose hash starts with prefix.
+func (r *gitRepo) expandHash(prefix string) (string, error) {
+	matches := map[string]bool{}
+	entries, _ := os.ReadDir(filepath.Join(r.commonDir, "objects", prefix[:2]))
+	for _, e := range entries {
+		if h := prefix[:2] + e.Name(); strings.HasPrefix(h, prefix) {
+			matches[h] = true
+		}
+	}
+	r.mu.Lock()
+	packs := r.packs
+	r.mu.Unlock()
+	for _, p := range packs {
+		for _, h := range p.withPrefix(prefix) {
+			matches[h] = true
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown revision %q", prefix)
+	case 1:
+		for h := range matches {
+			return h, nil
+		}
+	}
+	return "", fmt.Errorf("short hash %q is ambiguous", prefix)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// packFile is a packfile with its version 2 index held in memory.
+type packFile struct {
+	idxPath string
+	file    *os.File
+	idx     []byte
+	count   int
+}
+
+func openPackFile(idxPath string) (*packFile, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index", idxPath)
+	}
+	count := int(binary.BigEndian.Uint32(idx[8+255*4:]))
+	if len(idx) < 8+256*4+count*(20+4+4) {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath)
+	}
+	f, err := os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return &packFile{idxPath: idxPath, file: f, idx: idx, count: count}, nil
+}
+
+func (p *packFile) close() {
+	p.file.Close()
+}
+
+func (p *packFile) hashAt(i int) []byte {
+	start := 8 + 256*4 + i*20
+	return p.idx[start : start+20]
+}
+
+// fanout returns the range of index positions whose hashes start with b.
+func (p *packFile) fanout(b byte) (lo, hi int) {
+	if b > 0 {
+		lo = int(binary.BigEndian.Uint32(p.idx[8+int(b-1)*4:]))
+	}
+	hi = int(binary.BigEndian.Uint32(p.idx[8+int(b)*4:]))
+	return lo, hi
+}
+
+// find returns the pack offset of the object with the given raw hash.
+func (p *packFile) find(hash []byte) (int64, bool) {
+	lo, hi := p.fanout(hash[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashAt(lo+i), hash) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashAt(i), hash) {
+		return 0, false
+	}
+	return p.offsetAt(i), true
+}
+
+func (p *packFile) offsetAt(i int) int64 {
+	offsets := 8 + 256*4 + p.count*(20+4)
+	off := binary.BigEndian.Uint32(p.idx[offsets+i*4:])
+	if off&0x80000000 == 0 {
+		return int64(off)
+	}
+	large := offsets + p.count*4 + int(off&0x7fffffff)*8
+	if large+8 > len(p.idx) {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(p.idx[large:]))
+}
+
+// withPrefix returns the hex hashes in the pack that start with prefix.
+func (p *packFile) withPrefix(prefix string) []string {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+	lo, hi := p.fanout(byte(first))
+	var matches []string
+	for i := lo; i < hi; i++ {
+		if h := hex.EncodeToString(p.hashAt(i)); strings.HasPrefix(h, prefix) {
+			matches = append(matches, h)
+		}
+	}
+	return matches
+}
+
+// Pack object types.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypeNames = map[int]string{packCommit: "commit", packTree: "tree", packBlob: "blob", packTag: "tag"}
+
+// readAt reads the object at offset, resolving deltas against their bases.
+func (p *packFile) readAt(r *gitRepo, offset int64) (string, []byte, error) {
+	if offset < 0 {
+		return "", nil, fmt.Errorf("pack offset out of range")
+	}
+	var header [32]byte
+	n, err := p.file.ReadAt(header[:], offset)
+	if n == 0 {
+		return "", nil, err
+	}
+	typ := int(header[0]>>4) & 7
+	size := int64(header[0] & 0x0f)
+	pos := 1
+	for shift := 4; header[pos-1]&0x80 != 0; shift += 7 {
+		if pos >= n {
+			return "", nil, fmt.Errorf("pack object at %d: malformed header", offset)
+		}
+		size |= int64(header[pos]&0x7f) << shift
+		pos++
+	}
+
+	switch typ {
+	case packCommit, packTree, packBlob, packTag:
+		data, err := p.inflate(offset+int64(pos), size)
+		return packTypeNames[typ], data, err
+	case packOfsDelta:
+		if pos >= n {
+			return "", nil, fmt.Errorf("pack object at %d: malformed header", offset)
+		}
+		c := header[pos]
+		pos++
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if pos >= n {
+				return "", nil, fmt.Errorf("pack object at %d: malformed delta offset", offset)
+			}
+			c = header[pos]
+			pos++
+			rel = (rel+1)<<7 | int64(c&0x7f)
+		}
+		baseType, base, err := p.readAt(r, offset-rel)
+		if err != nil {
+			return "", nil, err
+		}
+		delta, err := p.inflate(offset+int64(pos), size)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	case packRefDelta:
+		if pos+20 > n {
+			return "", nil, fmt.Errorf("pack object at %d: malformed header", offset)
+		}
+		baseType, base, err := r.readObject(hex.EncodeToString(header[pos : pos+20]))
+		if err != nil {
+			return "", nil, err
+		}
+		delta, err := p.inflate(offset+int64(pos+20), size)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	}
+	return "", nil, fmt.Errorf("pack object at %d: unknown type %d", offset, typ)
+}
+
+// inflate decompresses size bytes of zlib data starting at offset.
+func (p *packFile) inflate(offset, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62)))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// applyDelta rebuilds an object from its base and a git delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta := deltaHeaderSize(delta)
+	if srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	dstSize, delta := deltaHeaderSize(delta)
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			var offset, size int
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, fmt.Errorf("truncated delta")
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, fmt.Errorf("invalid delta opcode")
+		}
+	}
+	if len(out) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return out, nil
+}
+
+func deltaHeaderSize(delta []byte) (int, []byte) {
+	size, shift := 0, 0
+	for len(delta) > 0 {
+		c := delta[0]
+		delta = delta[1:]
+		size |= int(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
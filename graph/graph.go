@@ -4,32 +4,103 @@ import (
 	"crypto/rand"
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Provenance records who or what created a node or edge, so that everything
+// produced by one author or agent session can be traced later.
+type Provenance struct {
+	Author  string `json:"author,omitempty"`
+	Session string `json:"session,omitempty"`
+	Tool    string `json:"tool,omitempty"`
+	Source  string `json:"source,omitempty"`
+}
+
+// IsZero reports whether no provenance field is set.
+func (p *Provenance) IsZero() bool {
+	return p == nil || *p == Provenance{}
+}
+
+// EvidenceNode cites lines of a file at a git commit. LineRanges is LineRef
+// parsed, so clients need not parse it. Repo identifies the file's repository
+// in every clone and Path is the file's slash-separated path within it, so
+// FilePath can be re-resolved against another machine's checkout; FilePath is
+// empty while that repository has no checkout on this one. LastCheck
+// is the outcome of the latest background validity check, if any, and
+// History records status changes and re-anchors.
 type EvidenceNode struct {
-	ID        string    `json:"id"`
-	FilePath  string    `json:"file_path"`
-	LineRef   string    `json:"line_ref"`
-	GitCommit string    `json:"git_commit"`
+	ID         string          `json:"id"`
+	FilePath   string          `json:"file_path,omitempty"`
+	LineRef    string          `json:"line_ref"`
+	LineRanges []LineRange     `json:"line_ranges,omitempty"`
+	Symbol     string          `json:"symbol,omitempty"`
+	Repo       string          `json:"repo,omitempty"`
+	Path       string          `json:"path,omitempty"`
+	GitCommit  string          `json:"git_commit"`
+	Provenance *Provenance     `json:"provenance,omitempty"`
+	LastCheck  *CheckRecord    `json:"last_check,omitempty"`
+	History    []TimelineEvent `json:"history,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Review states a claim moves through. New claims start as drafts; a
+// retracted claim is final.
+const (
+	StatusDraft     = "draft"
+	StatusVerified  = "verified"
+	StatusDisputed  = "disputed"
+	StatusRetracted = "retracted"
+)
+
+// Review records one status transition of a claim and who made it.
+type Review struct {
+	Status    string    `json:"status"`
+	Reviewer  string    `json:"reviewer"`
+	Comment   string    `json:"comment,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type ClaimNode struct {
-	ID        string    `json:"id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string      `json:"id"`
+	Content    string      `json:"content"`
+	Tags       []string    `json:"tags,omitempty"`
+	Status     string      `json:"status"`
+	Reviews    []Review    `json:"reviews,omitempty"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
 }
 
+// HasTag reports whether the claim carries the given tag.
+func (c *ClaimNode) HasTag(tag string) bool {
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Edge kinds describe how a piece of evidence bears on a claim.
+const (
+	EdgeSupports = "supports"
+	EdgeRefutes  = "refutes"
+	EdgeContext  = "context"
+)
+
 type Edge struct {
-	ClaimID    string `json:"claim_id"`
-	EvidenceID string `json:"evidence_id"`
+	ClaimID    string      `json:"claim_id"`
+	EvidenceID string      `json:"evidence_id"`
+	Kind       string      `json:"kind"`
+	Provenance *Provenance `json:"provenance,omitempty"`
 }
 
 type Graph struct {
 	Evidence map[string]*EvidenceNode `json:"evidence"`
 	Claims   map[string]*ClaimNode    `json:"claims"`
 	Edges    []Edge                   `json:"edges"`
+	Repos    map[string]*Repo         `json:"repos,omitempty"`
 }
 
 func New() *Graph {
@@ -37,9 +108,103 @@ func New() *Graph {
 		Evidence: make(map[string]*EvidenceNode),
 		Claims:   make(map[string]*ClaimNode),
 		Edges:    []Edge{},
+		Repos:    make(map[string]*Repo),
+	}
+}
+
+// Copy returns a deep copy of g, which can be read while g is changed.
+func (g *Graph) Copy() *Graph {
+	c := &Graph{
+		Evidence: make(map[string]*EvidenceNode, len(g.Evidence)),
+		Claims:   make(map[string]*ClaimNode, len(g.Claims)),
+		Edges:    make([]Edge, len(g.Edges)),
+		Repos:    make(map[string]*Repo, len(g.Repos)),
+	}
+	for id, ev := range g.Evidence {
+		e := *ev
+		e.LineRanges = append([]LineRange(nil), ev.LineRanges...)
+		e.History = append([]TimelineEvent(nil), ev.History...)
+		e.Provenance = copyProvenance(ev.Provenance)
+		if ev.LastCheck != nil {
+			check := *ev.LastCheck
+			e.LastCheck = &check
+		}
+		c.Evidence[id] = &e
+	}
+	for id, claim := range g.Claims {
+		cl := *claim
+		cl.Tags = append([]string(nil), claim.Tags...)
+		cl.Reviews = append([]Review(nil), claim.Reviews...)
+		cl.Provenance = copyProvenance(claim.Provenance)
+		c.Claims[id] = &cl
 	}
+	for i, e := range g.Edges {
+		e.Provenance = copyProvenance(e.Provenance)
+		c.Edges[i] = e
+	}
+	for id, r := range g.Repos {
+		repo := *r
+		c.Repos[id] = &repo
+	}
+	return c
 }
 
+func copyProvenance(p *Provenance) *Provenance {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// FillDefaults sets fields that were added after older data files were
+// written, so that loaded graphs look like freshly built ones, and points
+// evidence stored without a FilePath at its registered checkout.
+func (g *Graph) FillDefaults() {
+	if g.Repos == nil {
+		g.Repos = make(map[string]*Repo)
+	}
+	for _, c := range g.Claims {
+		if c.Status == "" {
+			c.Status = StatusDraft
+		}
+	}
+	// Older files could link a claim and evidence more than once; the last
+	// link made is the one kept.
+	index := map[edgeKey]int{}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if e.Kind == "" {
+			e.Kind = EdgeSupports
+		}
+		if i, ok := index[e.key()]; ok {
+			edges[i] = e
+			continue
+		}
+		index[e.key()] = len(edges)
+		edges = append(edges, e)
+	}
+	g.Edges = edges
+	for _, ev := range g.Evidence {
+		if ev.LineRanges == nil {
+			ev.LineRanges, _ = ParseLineRef(ev.LineRef)
+		}
+		if ev.FilePath == "" {
+			ev.FilePath = g.CheckoutPath(ev)
+		}
+	}
+}
+
+// setLineRef replaces the evidence's line reference, keeping LineRanges in
+// step. ref must be valid.
+func (ev *EvidenceNode) setLineRef(ref string) {
+	ev.LineRanges, _ = ParseLineRef(ref)
+	ev.LineRef = FormatLineRef(ev.LineRanges)
+}
+
+// AddEvidence adds an evidence node citing lines of the file at filePath as
+// of gitCommit. The line reference is stored normalized. Returns nil if the
+// path is not absolute, the commit is empty or the line reference is invalid.
 func (g *Graph) AddEvidence(filePath, lineRef, gitCommit string) *EvidenceNode {
 	if !filepath.IsAbs(filePath) {
 		return nil
@@ -47,35 +212,156 @@ func (g *Graph) AddEvidence(filePath, lineRef, gitCommit string) *EvidenceNode {
 	if gitCommit == "" {
 		return nil
 	}
+	ranges, err := ParseLineRef(lineRef)
+	if err != nil {
+		return nil
+	}
 	ev := &EvidenceNode{
-		ID:        newID(),
-		FilePath:  filePath,
-		LineRef:   lineRef,
-		GitCommit: gitCommit,
-		CreatedAt: time.Now(),
+		ID:         newID(),
+		FilePath:   filePath,
+		LineRef:    FormatLineRef(ranges),
+		LineRanges: ranges,
+		GitCommit:  gitCommit,
+		CreatedAt:  time.Now(),
 	}
 	g.Evidence[ev.ID] = ev
 	return ev
 }
 
-func (g *Graph) AddClaim(content string) *ClaimNode {
+// Validate checks the fields of evidence that are passed to git or joined
+// into file paths: GitCommit must be a full commit hash, FilePath absolute
+// and clean, and Path a clean path inside the repository. Evidence that did
+// not come through AddEvidence, such as a graph pulled from a remote or read
+// from committed files, must pass before it is stored.
+func (ev *EvidenceNode) Validate() error {
+	if ev.ID == "" {
+		return fmt.Errorf("evidence has no id")
+	}
+	if !isCommitHash(ev.GitCommit) {
+		return fmt.Errorf("evidence %s: git_commit %q is not a full commit hash", ev.ID, ev.GitCommit)
+	}
+	if ev.FilePath != "" && (!filepath.IsAbs(ev.FilePath) || filepath.Clean(ev.FilePath) != ev.FilePath) {
+		return fmt.Errorf("evidence %s: file_path %q is not a clean absolute path", ev.ID, ev.FilePath)
+	}
+	if ev.Path != "" && !isRepoPath(ev.Path) {
+		return fmt.Errorf("evidence %s: path %q is not a clean path inside the repository", ev.ID, ev.Path)
+	}
+	if ev.FilePath == "" && (ev.Repo == "" || ev.Path == "") {
+		return fmt.Errorf("evidence %s has neither a file_path nor a repo and path", ev.ID)
+	}
+	if _, err := ParseLineRef(ev.LineRef); err != nil {
+		return fmt.Errorf("evidence %s: %v", ev.ID, err)
+	}
+	return nil
+}
+
+// Validate checks every evidence node of a graph that came from elsewhere.
+// Returns an error describing the first invalid node.
+func (g *Graph) Validate() error {
+	ids := make([]string, 0, len(g.Evidence))
+	for id := range g.Evidence {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		ev := g.Evidence[id]
+		if ev == nil {
+			return fmt.Errorf("evidence %s is empty", id)
+		}
+		if ev.ID != id {
+			return fmt.Errorf("evidence %s is stored under id %s", ev.ID, id)
+		}
+		if err := ev.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCommitHash reports whether s is a full SHA-1 or SHA-256 commit hash.
+func isCommitHash(s string) bool {
+	return (len(s) == 40 || len(s) == 64) && isHex(s)
+}
+
+func (g *Graph) AddClaim(content string, tags ...string) *ClaimNode {
 	claim := &ClaimNode{
 		ID:        newID(),
 		Content:   content,
+		Tags:      normalizeTags(tags),
+		Status:    StatusDraft,
 		CreatedAt: time.Now(),
 	}
 	g.Claims[claim.ID] = claim
 	return claim
 }
 
+// ReviewClaim moves a claim to a new status, recording the reviewer and an
+// optional comment. Returns an error if the claim does not exist, the status
+// is unknown, no reviewer is given, or the claim has been retracted.
+func (g *Graph) ReviewClaim(claimID, status, reviewer, comment string) (*Review, error) {
+	claim, ok := g.Claims[claimID]
+	if !ok {
+		return nil, fmt.Errorf("claim %q not found", claimID)
+	}
+	switch status {
+	case StatusDraft, StatusVerified, StatusDisputed, StatusRetracted:
+	default:
+		return nil, fmt.Errorf("unknown status %q", status)
+	}
+	if strings.TrimSpace(reviewer) == "" {
+		return nil, fmt.Errorf("reviewer is required")
+	}
+	if claim.Status == StatusRetracted {
+		return nil, fmt.Errorf("claim %q has been retracted", claimID)
+	}
+	review := Review{
+		Status:    status,
+		Reviewer:  reviewer,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	}
+	claim.Status = status
+	claim.Reviews = append(claim.Reviews, review)
+	return &review, nil
+}
+
 func (g *Graph) LinkEvidence(claimID, evidenceID string) error {
-	if _, ok := g.Claims[claimID]; !ok {
-		return fmt.Errorf("claim %q not found", claimID)
+	return g.AddEdge(Edge{ClaimID: claimID, EvidenceID: evidenceID})
+}
+
+// edgeKey identifies an edge. A claim and a piece of evidence are joined by
+// at most one edge, which says how the evidence bears on the claim.
+type edgeKey struct{ claimID, evidenceID string }
+
+func (e Edge) key() edgeKey {
+	return edgeKey{e.ClaimID, e.EvidenceID}
+}
+
+// AddEdge adds an edge after checking that both of its endpoints exist and
+// that its kind is known. An edge without a kind supports its claim. Linking
+// a claim and evidence that are already linked replaces their edge, e.g. to
+// change its kind.
+func (g *Graph) AddEdge(e Edge) error {
+	switch e.Kind {
+	case "":
+		e.Kind = EdgeSupports
+	case EdgeSupports, EdgeRefutes, EdgeContext:
+	default:
+		return fmt.Errorf("unknown edge kind %q", e.Kind)
+	}
+	if _, ok := g.Claims[e.ClaimID]; !ok {
+		return fmt.Errorf("claim %q not found", e.ClaimID)
 	}
-	if _, ok := g.Evidence[evidenceID]; !ok {
-		return fmt.Errorf("evidence %q not found", evidenceID)
+	if _, ok := g.Evidence[e.EvidenceID]; !ok {
+		return fmt.Errorf("evidence %q not found", e.EvidenceID)
 	}
-	g.Edges = append(g.Edges, Edge{ClaimID: claimID, EvidenceID: evidenceID})
+	for i, existing := range g.Edges {
+		if existing.key() == e.key() {
+			g.Edges[i] = e
+			return nil
+		}
+	}
+	g.Edges = append(g.Edges, e)
 	return nil
 }
 
@@ -91,29 +377,95 @@ func (g *Graph) GetEvidenceForClaim(claimID string) []*EvidenceNode {
 	return result
 }
 
+// GetEdgesForClaim returns the edges from a claim to evidence that still
+// exists in the graph.
+func (g *Graph) GetEdgesForClaim(claimID string) []Edge {
+	var result []Edge
+	for _, edge := range g.Edges {
+		if edge.ClaimID == claimID {
+			if _, ok := g.Evidence[edge.EvidenceID]; ok {
+				result = append(result, edge)
+			}
+		}
+	}
+	return result
+}
+
 func (g *Graph) GetEvidence(id string) *EvidenceNode {
 	return g.Evidence[id]
 }
 
+// RemoveEvidence deletes an evidence node along with the edges linking it to
+// claims. Returns an error if the evidence is not found.
+func (g *Graph) RemoveEvidence(id string) error {
+	if _, ok := g.Evidence[id]; !ok {
+		return fmt.Errorf("evidence %q not found", id)
+	}
+	delete(g.Evidence, id)
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if e.EvidenceID != id {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+	return nil
+}
+
 // CheckEvidence returns true if the evidence is still valid (the referenced
 // file has not changed since the recorded git commit). Returns an error if
 // the evidence ID is not found or the git check fails.
 func (g *Graph) CheckEvidence(id string, checker GitChecker) (bool, error) {
-	ev, ok := g.Evidence[id]
-	if !ok {
-		return false, fmt.Errorf("evidence %q not found", id)
-	}
-	changed, err := checker.HasFileChangedSince(ev.GitCommit, ev.FilePath)
+	v, err := g.EvidenceValidity(id, checker, CheckOptions{})
 	if err != nil {
 		return false, err
 	}
-	return !changed, nil
+	return v.Valid, nil
 }
 
 func (g *Graph) GetClaim(id string) *ClaimNode {
 	return g.Claims[id]
 }
 
+// ClaimsWithTags returns the claims carrying every one of the given tags,
+// oldest first. With no tags it returns all claims.
+func (g *Graph) ClaimsWithTags(tags ...string) []*ClaimNode {
+	result := []*ClaimNode{}
+	for _, c := range g.Claims {
+		match := true
+		for _, t := range tags {
+			if !c.HasTag(t) {
+				match = false
+				break
+			}
+		}
+		if match {
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// normalizeTags trims whitespace, drops empty and duplicate tags, and sorts
+// the remainder so tag sets compare and serialize stably.
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	sort.Strings(result)
+	return result
+}
+
 func newID() string {
 	b := make([]byte, 16)
 	rand.Read(b)
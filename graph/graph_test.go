@@ -2,7 +2,9 @@ package graph
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewGraph(t *testing.T) {
@@ -45,6 +47,62 @@ func TestAddEvidence(t *testing.T) {
 	}
 }
 
+func TestCopy(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("c")
+	claim.Tags = []string{"auth"}
+	ev := g.AddEvidence("/home/user/f.go", "1", "abc123")
+	g.LinkEvidence(claim.ID, ev.ID)
+	g.RegisterRepo("root1", "/home/user", "")
+
+	c := g.Copy()
+	g.RecordCheck(ev.ID, EvidenceStale, "def456", time.Now())
+	claim.Tags[0] = "changed"
+	g.Repos["root1"].Path = "/elsewhere"
+	g.Edges[0].Kind = EdgeRefutes
+
+	copied := c.GetEvidence(ev.ID)
+	if copied == ev || copied.LastCheck != nil || len(copied.History) != len(ev.History)-1 {
+		t.Errorf("expected the copied evidence unchanged, got %+v", copied)
+	}
+	if c.GetClaim(claim.ID).Tags[0] != "auth" || c.Repos["root1"].Path != "/home/user" || c.Edges[0].Kind != EdgeSupports {
+		t.Error("expected the copy unchanged by changes to the graph")
+	}
+}
+
+func TestEvidenceValidate(t *testing.T) {
+	commit := strings.Repeat("ab", 20)
+	cases := []struct {
+		name string
+		ev   EvidenceNode
+		ok   bool
+	}{
+		{"absolute path", EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "1", GitCommit: commit}, true},
+		{"repo path", EvidenceNode{ID: "e", Repo: "r", Path: "pkg/a.go", LineRef: "1-2", GitCommit: commit}, true},
+		{"no id", EvidenceNode{FilePath: "/src/a.go", LineRef: "1", GitCommit: commit}, false},
+		{"option as commit", EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "1", GitCommit: "--output=/tmp/x"}, false},
+		{"short commit", EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "1", GitCommit: "abc123"}, false},
+		{"ref as commit", EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "1", GitCommit: "HEAD"}, false},
+		{"relative file path", EvidenceNode{ID: "e", FilePath: "src/a.go", LineRef: "1", GitCommit: commit}, false},
+		{"unclean file path", EvidenceNode{ID: "e", FilePath: "/src/../etc/a.go", LineRef: "1", GitCommit: commit}, false},
+		{"path outside repo", EvidenceNode{ID: "e", Repo: "r", Path: "../a.go", LineRef: "1", GitCommit: commit}, false},
+		{"absolute repo path", EvidenceNode{ID: "e", Repo: "r", Path: "/a.go", LineRef: "1", GitCommit: commit}, false},
+		{"no path", EvidenceNode{ID: "e", Repo: "r", LineRef: "1", GitCommit: commit}, false},
+		{"bad line ref", EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "x", GitCommit: commit}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.ev.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: expected valid %v, got error %v", tc.name, tc.ok, err)
+		}
+	}
+
+	g := New()
+	g.Evidence["other"] = &EvidenceNode{ID: "e", FilePath: "/src/a.go", LineRef: "1", GitCommit: commit}
+	if err := g.Validate(); err == nil {
+		t.Error("expected an error for evidence stored under another id")
+	}
+}
+
 func TestAddEvidenceRequiresGitCommit(t *testing.T) {
 	g := New()
 	ev := g.AddEvidence("/home/user/project/main.go", "1-3", "")
@@ -63,6 +121,27 @@ func TestAddEvidenceRequiresAbsolutePath(t *testing.T) {
 	}
 }
 
+func TestAddEvidenceNormalizesLineRef(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/project/main.go", "13-70, 1-3,2-5", "abc123")
+
+	if ev.LineRef != "1-5,13-70" {
+		t.Errorf("expected line ref %q, got %q", "1-5,13-70", ev.LineRef)
+	}
+	if len(ev.LineRanges) != 2 || ev.LineRanges[1] != (LineRange{Start: 13, End: 70}) {
+		t.Errorf("expected parsed ranges, got %v", ev.LineRanges)
+	}
+}
+
+func TestAddEvidenceRejectsInvalidLineRef(t *testing.T) {
+	g := New()
+	for _, ref := range []string{"abc", "70-13", ""} {
+		if ev := g.AddEvidence("/home/user/project/main.go", ref, "abc123"); ev != nil {
+			t.Errorf("expected nil for line ref %q", ref)
+		}
+	}
+}
+
 func TestAddClaim(t *testing.T) {
 	g := New()
 	claim := g.AddClaim("The authentication module validates tokens correctly")
@@ -81,6 +160,139 @@ func TestAddClaim(t *testing.T) {
 	}
 }
 
+func TestAddClaimWithTags(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Tokens expire after an hour", "perf", " auth ", "", "perf")
+
+	if len(claim.Tags) != 2 {
+		t.Fatalf("expected 2 tags, got %v", claim.Tags)
+	}
+	if claim.Tags[0] != "auth" || claim.Tags[1] != "perf" {
+		t.Errorf("expected tags [auth perf], got %v", claim.Tags)
+	}
+	if !claim.HasTag("auth") {
+		t.Error("expected claim to have tag auth")
+	}
+	if claim.HasTag("sprint-42") {
+		t.Error("expected claim not to have tag sprint-42")
+	}
+}
+
+func TestClaimsWithTags(t *testing.T) {
+	g := New()
+	authPerf := g.AddClaim("Token cache is bounded", "auth", "perf")
+	auth := g.AddClaim("Tokens are validated", "auth")
+	g.AddClaim("Untagged claim")
+
+	if got := g.ClaimsWithTags(); len(got) != 3 {
+		t.Errorf("expected all 3 claims without tags, got %d", len(got))
+	}
+
+	got := g.ClaimsWithTags("auth")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 claims tagged auth, got %d", len(got))
+	}
+	ids := map[string]bool{got[0].ID: true, got[1].ID: true}
+	if !ids[authPerf.ID] || !ids[auth.ID] {
+		t.Error("expected both auth claims")
+	}
+
+	got = g.ClaimsWithTags("auth", "perf")
+	if len(got) != 1 || got[0].ID != authPerf.ID {
+		t.Errorf("expected only the auth+perf claim, got %v", got)
+	}
+
+	if got := g.ClaimsWithTags("missing"); len(got) != 0 {
+		t.Errorf("expected no claims for unknown tag, got %d", len(got))
+	}
+}
+
+func TestNewClaimIsDraft(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+
+	if claim.Status != StatusDraft {
+		t.Errorf("expected status %q, got %q", StatusDraft, claim.Status)
+	}
+}
+
+func TestReviewClaim(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+
+	review, err := g.ReviewClaim(claim.ID, StatusVerified, "alice", "checked the middleware")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if review.Reviewer != "alice" || review.Comment != "checked the middleware" {
+		t.Errorf("unexpected review %+v", review)
+	}
+	if review.CreatedAt.IsZero() {
+		t.Error("expected non-zero created_at")
+	}
+	if claim.Status != StatusVerified {
+		t.Errorf("expected status %q, got %q", StatusVerified, claim.Status)
+	}
+
+	if _, err := g.ReviewClaim(claim.ID, StatusDisputed, "bob", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claim.Status != StatusDisputed {
+		t.Errorf("expected status %q, got %q", StatusDisputed, claim.Status)
+	}
+	if len(claim.Reviews) != 2 {
+		t.Fatalf("expected 2 reviews, got %d", len(claim.Reviews))
+	}
+	if claim.Reviews[0].Status != StatusVerified || claim.Reviews[1].Status != StatusDisputed {
+		t.Errorf("expected reviews in order, got %+v", claim.Reviews)
+	}
+}
+
+func TestReviewClaimRejectsInvalidInput(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+
+	if _, err := g.ReviewClaim("nonexistent", StatusVerified, "alice", ""); err == nil {
+		t.Error("expected error for nonexistent claim")
+	}
+	if _, err := g.ReviewClaim(claim.ID, "approved", "alice", ""); err == nil {
+		t.Error("expected error for unknown status")
+	}
+	if _, err := g.ReviewClaim(claim.ID, StatusVerified, " ", ""); err == nil {
+		t.Error("expected error for missing reviewer")
+	}
+	if len(claim.Reviews) != 0 || claim.Status != StatusDraft {
+		t.Errorf("expected claim to be unchanged, got status %q with %d reviews", claim.Status, len(claim.Reviews))
+	}
+}
+
+func TestReviewClaimRetractedIsFinal(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+
+	if _, err := g.ReviewClaim(claim.ID, StatusRetracted, "alice", "wrong function"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := g.ReviewClaim(claim.ID, StatusVerified, "bob", ""); err == nil {
+		t.Error("expected error reviewing a retracted claim")
+	}
+	if claim.Status != StatusRetracted {
+		t.Errorf("expected status %q, got %q", StatusRetracted, claim.Status)
+	}
+}
+
+func TestFillDefaultsSetsDraftStatus(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+	claim.Status = ""
+
+	g.FillDefaults()
+
+	if claim.Status != StatusDraft {
+		t.Errorf("expected status %q, got %q", StatusDraft, claim.Status)
+	}
+}
+
 func TestLinkEvidenceToClaim(t *testing.T) {
 	g := New()
 	claim := g.AddClaim("Auth works")
@@ -100,6 +312,85 @@ func TestLinkEvidenceToClaim(t *testing.T) {
 	if g.Edges[0].EvidenceID != ev.ID {
 		t.Errorf("expected evidence ID %q, got %q", ev.ID, g.Edges[0].EvidenceID)
 	}
+	if g.Edges[0].Kind != EdgeSupports {
+		t.Errorf("expected kind %q, got %q", EdgeSupports, g.Edges[0].Kind)
+	}
+}
+
+func TestAddEdgeRefutes(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Tokens never expire")
+	ev := g.AddEvidence("/home/user/auth.go", "40-44", "abc123")
+
+	if err := g.AddEdge(Edge{ClaimID: claim.ID, EvidenceID: ev.ID, Kind: EdgeRefutes}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.Edges[0].Kind != EdgeRefutes {
+		t.Errorf("expected kind %q, got %q", EdgeRefutes, g.Edges[0].Kind)
+	}
+}
+
+func TestAddEdgeUnknownKind(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+	ev := g.AddEvidence("/home/user/auth.go", "10-25", "abc123")
+
+	if err := g.AddEdge(Edge{ClaimID: claim.ID, EvidenceID: ev.ID, Kind: "contradicts"}); err == nil {
+		t.Error("expected error for unknown edge kind")
+	}
+	if len(g.Edges) != 0 {
+		t.Errorf("expected no edges, got %d", len(g.Edges))
+	}
+}
+
+func TestAddEdgeReplacesLink(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+	ev := g.AddEvidence("/home/user/auth.go", "10-25", "abc123")
+
+	g.LinkEvidence(claim.ID, ev.ID)
+	if err := g.AddEdge(Edge{ClaimID: claim.ID, EvidenceID: ev.ID, Kind: EdgeRefutes}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.Edges) != 1 || g.Edges[0].Kind != EdgeRefutes {
+		t.Errorf("expected one refuting edge, got %+v", g.Edges)
+	}
+}
+
+func TestFillDefaultsKeepsOneEdgePerLink(t *testing.T) {
+	g := New()
+	g.Edges = []Edge{
+		{ClaimID: "c1", EvidenceID: "e1"},
+		{ClaimID: "c1", EvidenceID: "e2"},
+		{ClaimID: "c1", EvidenceID: "e1", Kind: EdgeContext},
+	}
+
+	g.FillDefaults()
+	if len(g.Edges) != 2 || g.Edges[0].EvidenceID != "e1" || g.Edges[0].Kind != EdgeContext {
+		t.Errorf("expected the last link to e1 and the link to e2, got %+v", g.Edges)
+	}
+}
+
+func TestFillDefaultsSetsEdgeKind(t *testing.T) {
+	g := New()
+	g.Edges = append(g.Edges, Edge{ClaimID: "c1", EvidenceID: "e1"})
+
+	g.FillDefaults()
+
+	if g.Edges[0].Kind != EdgeSupports {
+		t.Errorf("expected kind %q, got %q", EdgeSupports, g.Edges[0].Kind)
+	}
+}
+
+func TestFillDefaultsParsesLineRanges(t *testing.T) {
+	g := New()
+	g.Evidence["e1"] = &EvidenceNode{ID: "e1", FilePath: "/home/user/auth.go", LineRef: "3,1-2", GitCommit: "abc123"}
+
+	g.FillDefaults()
+
+	if len(g.Evidence["e1"].LineRanges) != 1 || g.Evidence["e1"].LineRanges[0] != (LineRange{Start: 1, End: 3}) {
+		t.Errorf("expected line ranges [{1 3}], got %v", g.Evidence["e1"].LineRanges)
+	}
 }
 
 func TestLinkEvidenceInvalidClaim(t *testing.T) {
@@ -122,6 +413,50 @@ func TestLinkEvidenceInvalidEvidence(t *testing.T) {
 	}
 }
 
+func TestAddEdgeWithProvenance(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+	ev := g.AddEvidence("/home/user/auth.go", "10-25", "abc123")
+
+	prov := &Provenance{Author: "review-bot", Session: "s-1", Tool: "trees-cli"}
+	if err := g.AddEdge(Edge{ClaimID: claim.ID, EvidenceID: ev.ID, Provenance: prov}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	edges := g.GetEdgesForClaim(claim.ID)
+	if len(edges) != 1 {
+		t.Fatalf("expected 1 edge, got %d", len(edges))
+	}
+	if edges[0].Provenance.Session != "s-1" {
+		t.Errorf("expected session %q, got %q", "s-1", edges[0].Provenance.Session)
+	}
+}
+
+func TestAddEdgeInvalidEndpoints(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+
+	if err := g.AddEdge(Edge{ClaimID: claim.ID, EvidenceID: "nonexistent"}); err == nil {
+		t.Error("expected error for nonexistent evidence")
+	}
+	if len(g.Edges) != 0 {
+		t.Errorf("expected no edges, got %d", len(g.Edges))
+	}
+}
+
+func TestProvenanceIsZero(t *testing.T) {
+	var nilProv *Provenance
+	if !nilProv.IsZero() {
+		t.Error("expected nil provenance to be zero")
+	}
+	if !(&Provenance{}).IsZero() {
+		t.Error("expected empty provenance to be zero")
+	}
+	if (&Provenance{Source: "design doc"}).IsZero() {
+		t.Error("expected provenance with a source not to be zero")
+	}
+}
+
 func TestGetEvidenceForClaim(t *testing.T) {
 	g := New()
 	claim := g.AddClaim("Auth works")
@@ -167,6 +502,29 @@ func TestGetEvidenceByIDNotFound(t *testing.T) {
 	}
 }
 
+func TestRemoveEvidence(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("Auth works")
+	ev := g.AddEvidence("/home/user/auth.go", "10-25", "abc123")
+	other := g.AddEvidence("/home/user/auth_test.go", "1-5", "abc123")
+	g.LinkEvidence(claim.ID, ev.ID)
+	g.LinkEvidence(claim.ID, other.ID)
+
+	if err := g.RemoveEvidence(ev.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g.GetEvidence(ev.ID) != nil {
+		t.Error("expected evidence to be removed")
+	}
+	if len(g.Edges) != 1 || g.Edges[0].EvidenceID != other.ID {
+		t.Errorf("expected only the edge to the other evidence, got %+v", g.Edges)
+	}
+
+	if err := g.RemoveEvidence(ev.ID); err == nil {
+		t.Error("expected error removing nonexistent evidence")
+	}
+}
+
 func TestGetClaimByID(t *testing.T) {
 	g := New()
 	claim := g.AddClaim("test claim")
@@ -0,0 +1,107 @@
+package graph
+
+import "path/filepath"
+
+// ImpactedEvidence is an evidence node whose cited lines a diff changes.
+type ImpactedEvidence struct {
+	*EvidenceNode
+	// Kind is how the evidence bears on the claim it is listed under.
+	Kind string `json:"kind"`
+	// BasePath and BaseLineRef are where the citation sits in the diff's
+	// base when it has moved since it was recorded.
+	BasePath    string `json:"base_path,omitempty"`
+	BaseLineRef string `json:"base_line_ref,omitempty"`
+	// Hunks are the runs of changed lines that touch the citation, as
+	// "-start,count +start,count" ranges.
+	Hunks []string `json:"hunks"`
+}
+
+// UncheckedEvidence is evidence whose citation could not be followed to the
+// diff's base, and why. Whether the diff touches it is unknown.
+type UncheckedEvidence struct {
+	*EvidenceNode
+	Error string `json:"error"`
+}
+
+// ImpactedClaim is a claim with the evidence a diff changes.
+type ImpactedClaim struct {
+	*ClaimNode
+	Evidence []ImpactedEvidence `json:"evidence"`
+}
+
+// Impact lists the claims whose evidence cites lines that patch, a unified
+// diff with paths relative to the checkout at root, changes or removes. The
+// patch is taken to apply to HEAD, or to opts.Ref when set. Citations are
+// followed to where they sit there using normalized validity, so evidence
+// recorded at an older commit is matched by its current lines; evidence that
+// is already invalid there is left to validation. Claims are in creation
+// order. Evidence whose validity check fails is returned as unchecked, in
+// ID order, rather than failing the rest.
+func (g *Graph) Impact(patch []byte, root string, checker GitChecker, opts CheckOptions) ([]ImpactedClaim, []UncheckedEvidence) {
+	hunks := map[string][]diffHunk{}
+	for _, f := range parsePatch(patch) {
+		// A created file holds no cited lines yet.
+		if f.OldPath != "" {
+			path := filepath.Join(root, filepath.FromSlash(f.OldPath))
+			hunks[path] = append(hunks[path], f.Hunks...)
+		}
+	}
+
+	opts.Normalize, opts.WorkingTree = true, false
+	touched := map[string]*ImpactedEvidence{}
+	unchecked := []UncheckedEvidence{}
+	for _, ev := range g.EvidenceUnder(root) {
+		v, err := g.EvidenceValidity(ev.ID, checker, opts)
+		if err != nil {
+			unchecked = append(unchecked, UncheckedEvidence{EvidenceNode: ev, Error: err.Error()})
+			continue
+		}
+		if !v.Valid {
+			continue
+		}
+		path, ref := ev.FilePath, ev.LineRef
+		if v.CurrentPath != "" {
+			path = v.CurrentPath
+		}
+		if v.CurrentLineRef != "" {
+			ref = v.CurrentLineRef
+		}
+		lines, err := ParseLineRef(ref)
+		if err != nil {
+			continue
+		}
+		var hit []string
+		for _, h := range hunks[path] {
+			if rangesTouched(lines, []LineRange{h.oldRange()}) {
+				hit = append(hit, h.String())
+			}
+		}
+		if len(hit) > 0 {
+			touched[ev.ID] = &ImpactedEvidence{
+				EvidenceNode: ev,
+				BasePath:     v.CurrentPath,
+				BaseLineRef:  v.CurrentLineRef,
+				Hunks:        hit,
+			}
+		}
+	}
+
+	impacted := []ImpactedClaim{}
+	if len(touched) == 0 {
+		return impacted, unchecked
+	}
+	for _, c := range g.ClaimsWithTags() {
+		var evidence []ImpactedEvidence
+		for _, e := range g.GetEdgesForClaim(c.ID) {
+			if t, ok := touched[e.EvidenceID]; ok {
+				ie := *t
+				ie.Kind = e.Kind
+				evidence = append(evidence, ie)
+			}
+		}
+		if len(evidence) > 0 {
+			impacted = append(impacted, ImpactedClaim{ClaimNode: c, Evidence: evidence})
+		}
+	}
+	return impacted, unchecked
+}
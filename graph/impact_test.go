@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImpact(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n\nfunc A() int {\n\treturn 1\n}\n\nfunc B() int {\n\treturn 2\n}\n")
+	first := r.commit("add A and B")
+
+	g := New()
+	a := g.AddEvidence(r.path("a.go"), "3-5", first)
+	b := g.AddEvidence(r.path("a.go"), "7-9", first)
+	claimA, claimB := g.AddClaim("A returns one"), g.AddClaim("B returns two")
+	g.LinkEvidence(claimA.ID, a.ID)
+	g.AddEdge(Edge{ClaimID: claimB.ID, EvidenceID: b.ID, Kind: EdgeContext})
+
+	// Lines added above both functions move the citations down by two.
+	r.write("a.go", "package a\n\n// A and B are constants.\n\nfunc A() int {\n\treturn 1\n}\n\nfunc B() int {\n\treturn 2\n}\n")
+	r.commit("document")
+	r.write("a.go", "package a\n\n// A and B are constants.\n\nfunc A() int {\n\treturn 1\n}\n\nfunc B() int {\n\treturn 3\n}\n")
+	patch := r.git("diff")
+
+	checker := &ExecGitChecker{}
+	impacted, unchecked := g.Impact([]byte(patch+"\n"), r.dir, checker, CheckOptions{})
+	if len(unchecked) != 0 {
+		t.Fatalf("expected every citation checked, got %+v", unchecked)
+	}
+	if len(impacted) != 1 || impacted[0].ID != claimB.ID {
+		t.Fatalf("expected only the claim about B, got %+v", impacted)
+	}
+	ev := impacted[0].Evidence
+	if len(ev) != 1 || ev[0].ID != b.ID || ev[0].Kind != EdgeContext {
+		t.Fatalf("expected B's evidence as context, got %+v", ev)
+	}
+	if ev[0].BaseLineRef != "9-11" || len(ev[0].Hunks) != 1 || ev[0].Hunks[0] != "-10,1 +10,1" {
+		t.Errorf("expected lines 9-11 touched by -10,1 +10,1, got %s touched by %v", ev[0].BaseLineRef, ev[0].Hunks)
+	}
+
+	// Taken to apply to the first commit, the hunk lands below B.
+	impacted, _ = g.Impact([]byte(patch+"\n"), r.dir, checker, CheckOptions{Ref: first})
+	if len(impacted) != 0 {
+		t.Errorf("expected no claims against %s, got %+v", first, impacted)
+	}
+
+	impacted, _ = g.Impact(nil, r.dir, checker, CheckOptions{})
+	if len(impacted) != 0 {
+		t.Errorf("expected no claims for an empty patch, got %+v", impacted)
+	}
+
+	// Evidence at a commit the checkout lacks is reported, and the rest is
+	// still checked.
+	lost := g.AddEvidence(r.path("a.go"), "3-5", strings.Repeat("0", 40))
+	impacted, unchecked = g.Impact([]byte(patch+"\n"), r.dir, checker, CheckOptions{})
+	if len(impacted) != 1 || impacted[0].ID != claimB.ID {
+		t.Errorf("expected the claim about B despite the unknown commit, got %+v", impacted)
+	}
+	if len(unchecked) != 1 || unchecked[0].ID != lost.ID || unchecked[0].Error == "" {
+		t.Errorf("expected %s unchecked with an error, got %+v", lost.ID, unchecked)
+	}
+}
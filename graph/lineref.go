@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of 1-based line numbers.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// String formats the range as "N" or "N-M".
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return strconv.Itoa(r.Start) + "-" + strconv.Itoa(r.End)
+}
+
+// ParseLineRef parses a line reference such as "1-3,7,13-70" into ranges
+// that are sorted, with overlapping and adjacent ranges merged. Returns an
+// error if the reference is empty or malformed, a line number is below 1, or
+// a range ends before it starts.
+func ParseLineRef(ref string) ([]LineRange, error) {
+	if strings.TrimSpace(ref) == "" {
+		return nil, fmt.Errorf("line reference is required")
+	}
+	var ranges []LineRange
+	for _, part := range strings.Split(ref, ",") {
+		part = strings.TrimSpace(part)
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := parseLineNumber(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line reference %q: %v", ref, err)
+		}
+		end := start
+		if isRange {
+			if end, err = parseLineNumber(endStr); err != nil {
+				return nil, fmt.Errorf("invalid line reference %q: %v", ref, err)
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid line reference %q: range %s ends before it starts", ref, part)
+		}
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return normalizeRanges(ranges), nil
+}
+
+// FormatLineRef formats ranges as a line reference, sorting and merging them
+// first.
+func FormatLineRef(ranges []LineRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range normalizeRanges(ranges) {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseLineNumber(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a line number", s)
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("line numbers start at 1, got %d", n)
+	}
+	return n, nil
+}
+
+// normalizeRanges returns a sorted copy of ranges with overlapping and
+// adjacent ranges merged.
+func normalizeRanges(ranges []LineRange) []LineRange {
+	sorted := append([]LineRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Start != sorted[j].Start {
+			return sorted[i].Start < sorted[j].Start
+		}
+		return sorted[i].End < sorted[j].End
+	})
+	var merged []LineRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End+1 {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,45 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected []LineRange
+	}{
+		{"7", []LineRange{{7, 7}}},
+		{"1-3,7,13-70", []LineRange{{1, 3}, {7, 7}, {13, 70}}},
+		{"13-70, 1-3", []LineRange{{1, 3}, {13, 70}}},
+		{"1-5,3-8", []LineRange{{1, 8}}},
+		{"1-3,4,6", []LineRange{{1, 4}, {6, 6}}},
+		{"2,2", []LineRange{{2, 2}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLineRef(tt.ref)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.ref, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.ref, tt.expected, got)
+		}
+	}
+}
+
+func TestParseLineRefInvalid(t *testing.T) {
+	for _, ref := range []string{"", "abc", "70-13", "0", "1-", "-3", "1,,2", "1-2-3", "-1"} {
+		if _, err := ParseLineRef(ref); err == nil {
+			t.Errorf("%q: expected error", ref)
+		}
+	}
+}
+
+func TestFormatLineRef(t *testing.T) {
+	got := FormatLineRef([]LineRange{{13, 70}, {7, 7}, {1, 3}, {2, 4}})
+	if got != "1-4,7,13-70" {
+		t.Errorf("expected %q, got %q", "1-4,7,13-70", got)
+	}
+}
@@ -0,0 +1,268 @@
+package graph
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MergeStats counts what a merge added to a graph and which existing nodes
+// it changed.
+type MergeStats struct {
+	ClaimsAdded     int `json:"claims_added"`
+	ClaimsUpdated   int `json:"claims_updated"`
+	EvidenceAdded   int `json:"evidence_added"`
+	EvidenceUpdated int `json:"evidence_updated"`
+	EdgesAdded      int `json:"edges_added"`
+	EdgesUpdated    int `json:"edges_updated"`
+	ReposAdded      int `json:"repos_added"`
+}
+
+// Merge adds the nodes and edges of other, such as a teammate's copy of the
+// graph, to g. The result is the same whichever copy is merged into which:
+//
+//   - Nodes are matched by ID and edges, as in AddEdge, by the claim and
+//     evidence they join. Neither is ever removed, so a node deleted on one
+//     side and kept on the other is kept.
+//   - A claim's reviews are combined in time order and its status is that of
+//     the latest review. Tags are combined.
+//   - When evidence was re-anchored on both sides, the latest re-anchor
+//     wins, and the re-anchors are combined in the history.
+//   - Remaining disagreements, such as an edge's kind, are settled by
+//     comparing the two versions' content.
+//
+// What the background checker records, LastCheck and status history, is
+// only ever kept from g, as are repositories' checkout paths and default
+// branches. Evidence from other in a repository g knows is pointed at g's
+// checkout of it. other should pass Validate first.
+func (g *Graph) Merge(other *Graph) MergeStats {
+	var stats MergeStats
+	checkouts := map[string]string{}
+	for id, r := range g.Repos {
+		checkouts[id] = r.Path
+	}
+
+	// Where other checks a repository out says nothing about this machine,
+	// and the default branch is passed to git, so both are left for
+	// registering a local checkout to fill in.
+	for id, theirs := range other.Repos {
+		if ours, ok := g.Repos[id]; ok {
+			if theirs.CreatedAt.Before(ours.CreatedAt) {
+				ours.CreatedAt = theirs.CreatedAt
+			}
+			continue
+		}
+		g.Repos[id] = &Repo{ID: id, CreatedAt: theirs.CreatedAt}
+		stats.ReposAdded++
+	}
+
+	for id, theirs := range other.Claims {
+		ours, ok := g.Claims[id]
+		if !ok {
+			c := *theirs
+			c.Tags = append([]string(nil), theirs.Tags...)
+			c.Reviews = append([]Review(nil), theirs.Reviews...)
+			g.Claims[id] = &c
+			stats.ClaimsAdded++
+			continue
+		}
+		if mergeClaim(ours, theirs) {
+			stats.ClaimsUpdated++
+		}
+	}
+
+	for id, theirs := range other.Evidence {
+		ours, ok := g.Evidence[id]
+		if !ok {
+			ev := *theirs
+			ev.LastCheck = nil
+			ev.History = sharedHistory(theirs.History)
+			ev.LineRanges = append([]LineRange(nil), theirs.LineRanges...)
+			ev.resolveCheckout(checkouts)
+			g.Evidence[id] = &ev
+			stats.EvidenceAdded++
+			continue
+		}
+		if mergeEvidence(ours, theirs, checkouts) {
+			stats.EvidenceUpdated++
+		}
+	}
+
+	index := map[edgeKey]int{}
+	for i, e := range g.Edges {
+		index[e.key()] = i
+	}
+	for _, theirs := range other.Edges {
+		i, ok := index[theirs.key()]
+		if !ok {
+			index[theirs.key()] = len(g.Edges)
+			g.Edges = append(g.Edges, theirs)
+			stats.EdgesAdded++
+			continue
+		}
+		if ours := g.Edges[i]; ours.Kind != theirs.Kind && contentAfter(theirs, ours) {
+			g.Edges[i] = theirs
+			stats.EdgesUpdated++
+		}
+	}
+	return stats
+}
+
+// mergeClaim merges theirs into ours and reports whether ours changed. The
+// content comes from the side reviewed last.
+func mergeClaim(ours, theirs *ClaimNode) bool {
+	before := *ours
+	type body struct {
+		Content    string
+		Provenance *Provenance
+		CreatedAt  time.Time
+	}
+	ob := body{ours.Content, ours.Provenance, ours.CreatedAt}
+	tb := body{theirs.Content, theirs.Provenance, theirs.CreatedAt}
+	ol, tl := latestReview(ours), latestReview(theirs)
+	if tl.After(ol) || (tl.Equal(ol) && contentAfter(tb, ob)) {
+		ours.Content, ours.Provenance, ours.CreatedAt = theirs.Content, theirs.Provenance, theirs.CreatedAt
+	}
+	ours.Tags = normalizeTags(append(append([]string(nil), ours.Tags...), theirs.Tags...))
+	ours.Reviews = mergeReviews(ours.Reviews, theirs.Reviews)
+	if len(ours.Reviews) > 0 {
+		ours.Status = ours.Reviews[len(ours.Reviews)-1].Status
+	} else if contentAfter(theirs.Status, ours.Status) {
+		ours.Status = theirs.Status
+	}
+	return !sameContent(&before, ours)
+}
+
+func latestReview(c *ClaimNode) time.Time {
+	var latest time.Time
+	for _, r := range c.Reviews {
+		if r.CreatedAt.After(latest) {
+			latest = r.CreatedAt
+		}
+	}
+	return latest
+}
+
+// mergeReviews combines two review lists in time order, dropping reviews
+// that appear in both.
+func mergeReviews(ours, theirs []Review) []Review {
+	merged := append([]Review(nil), ours...)
+	for _, t := range theirs {
+		dup := false
+		for _, o := range ours {
+			if o.Status == t.Status && o.Reviewer == t.Reviewer && o.Comment == t.Comment && o.CreatedAt.Equal(t.CreatedAt) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			merged = append(merged, t)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if !merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+		}
+		return contentAfter(merged[j], merged[i])
+	})
+	return merged
+}
+
+// mergeEvidence merges theirs into ours and reports whether ours changed.
+// The citation, where it points and at which commit, comes from the side
+// re-anchored last.
+func mergeEvidence(ours, theirs *EvidenceNode, checkouts map[string]string) bool {
+	changed := false
+	if citationAfter(theirs, ours) {
+		ours.FilePath, ours.Repo, ours.Path = theirs.FilePath, theirs.Repo, theirs.Path
+		ours.LineRef, ours.Symbol, ours.GitCommit = theirs.LineRef, theirs.Symbol, theirs.GitCommit
+		ours.LineRanges = append([]LineRange(nil), theirs.LineRanges...)
+		ours.resolveCheckout(checkouts)
+		changed = true
+	}
+	for _, t := range sharedHistory(theirs.History) {
+		dup := false
+		for _, o := range ours.History {
+			if sameEvent(o, t) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			ours.History = append(ours.History, t)
+			changed = true
+		}
+	}
+	sort.SliceStable(ours.History, func(i, j int) bool {
+		return ours.History[i].At.Before(ours.History[j].At)
+	})
+	return changed
+}
+
+// citationAfter reports whether a's citation should replace b's: it was
+// re-anchored later, or at the same time to a different place that sorts
+// after b's. Paths are compared within the repository, since FilePath
+// differs between machines.
+func citationAfter(a, b *EvidenceNode) bool {
+	at, bt := lastReanchor(a), lastReanchor(b)
+	if !at.Equal(bt) {
+		return at.After(bt)
+	}
+	type citation struct{ Repo, Path, LineRef, Symbol, GitCommit string }
+	ac := citation{a.Repo, a.Path, a.LineRef, a.Symbol, a.GitCommit}
+	bc := citation{b.Repo, b.Path, b.LineRef, b.Symbol, b.GitCommit}
+	if a.Repo == "" || b.Repo == "" {
+		ac.Path, bc.Path = a.FilePath, b.FilePath
+	}
+	return contentAfter(ac, bc)
+}
+
+func lastReanchor(ev *EvidenceNode) time.Time {
+	latest := ev.CreatedAt
+	for _, e := range ev.History {
+		if e.Kind == EventReanchored && e.At.After(latest) {
+			latest = e.At
+		}
+	}
+	return latest
+}
+
+// sharedHistory returns the events of history that are not status changes
+// found by this machine's checks.
+func sharedHistory(history []TimelineEvent) []TimelineEvent {
+	var shared []TimelineEvent
+	for _, e := range history {
+		if e.Kind != EventStatus {
+			shared = append(shared, e)
+		}
+	}
+	return shared
+}
+
+func sameEvent(a, b TimelineEvent) bool {
+	at, bt := a.At, b.At
+	a.At, b.At = time.Time{}, time.Time{}
+	return a == b && at.Equal(bt)
+}
+
+// resolveCheckout points evidence in a repository with a known checkout at
+// the file in that checkout.
+func (ev *EvidenceNode) resolveCheckout(checkouts map[string]string) {
+	if dir, ok := checkouts[ev.Repo]; ok && dir != "" && ev.Path != "" {
+		ev.FilePath = filepath.Join(dir, filepath.FromSlash(ev.Path))
+	}
+}
+
+// sameContent reports whether a and b encode to the same JSON.
+func sameContent(a, b interface{}) bool {
+	return !contentAfter(a, b) && !contentAfter(b, a)
+}
+
+// contentAfter reports whether a's JSON encoding sorts after b's. It settles
+// merge conflicts the same way on every machine.
+func contentAfter(a, b interface{}) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	return string(aj) > string(bj)
+}
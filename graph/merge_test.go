@@ -0,0 +1,159 @@
+package graph
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// mergeFixture returns two copies of a graph that were edited apart: each
+// added a claim, both reviewed the shared claim, and both re-anchored the
+// shared evidence.
+func mergeFixture(t *testing.T) (*Graph, *Graph) {
+	t.Helper()
+	base := New()
+	base.RegisterRepo("root1", "/home/alice/project", "main")
+	claim := base.AddClaim("shared claim", "auth")
+	ev := base.AddEvidence("/home/alice/project/auth.go", "10-12", "abc123")
+	ev.Repo, ev.Path = "root1", "auth.go"
+	base.LinkEvidence(claim.ID, ev.ID)
+	data, err := json.Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copyOf := func(checkout string) *Graph {
+		g := New()
+		if err := json.Unmarshal(data, g); err != nil {
+			t.Fatal(err)
+		}
+		g.ResolveRepoPaths(map[string]string{"root1": checkout})
+		return g
+	}
+	alice, bob := copyOf("/home/alice/project"), copyOf("/home/bob/src/project")
+
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	alice.AddClaim("alice's claim")
+	alice.Claims[claim.ID].Reviews = []Review{{Status: StatusVerified, Reviewer: "alice", CreatedAt: t0}}
+	alice.Claims[claim.ID].Status = StatusVerified
+	alice.Evidence[ev.ID].History = []TimelineEvent{{Kind: EventReanchored, At: t0, Commit: "def456"}}
+	alice.Evidence[ev.ID].GitCommit = "def456"
+	alice.RecordCheck(ev.ID, EvidenceValid, "def456", t0)
+
+	bob.AddClaim("bob's claim", "perf")
+	bob.Claims[claim.ID].Reviews = []Review{{Status: StatusDisputed, Reviewer: "bob", CreatedAt: t0.Add(time.Hour)}}
+	bob.Claims[claim.ID].Status = StatusDisputed
+	bob.Evidence[ev.ID].History = []TimelineEvent{{Kind: EventReanchored, At: t0.Add(2 * time.Hour), Commit: "fff999", LineRef: "20-22"}}
+	bob.Evidence[ev.ID].GitCommit, bob.Evidence[ev.ID].LineRef = "fff999", "20-22"
+	bob.RecordCheck(ev.ID, EvidenceStale, "fff999", t0)
+	return alice, bob
+}
+
+func TestMerge(t *testing.T) {
+	alice, bob := mergeFixture(t)
+	var claimID, evID string
+	for id, c := range alice.Claims {
+		if c.Content == "shared claim" {
+			claimID = id
+		}
+	}
+	for id := range alice.Evidence {
+		evID = id
+	}
+
+	bob.RegisterRepo("root2", "/home/bob/tools", "origin/main")
+
+	stats := alice.Merge(bob)
+	if stats.ClaimsAdded != 1 || stats.ClaimsUpdated != 1 || stats.EvidenceUpdated != 1 || stats.EdgesAdded != 0 || stats.ReposAdded != 1 {
+		t.Errorf("expected 1 claim and 1 repository added, 1 claim and 1 evidence updated, got %+v", stats)
+	}
+	if r := alice.Repos["root2"]; r == nil || r.Path != "" || r.DefaultBranch != "" {
+		t.Errorf("expected bob's repository without his checkout or default branch, got %+v", r)
+	}
+	if len(alice.Claims) != 3 {
+		t.Errorf("expected 3 claims, got %d", len(alice.Claims))
+	}
+	c := alice.Claims[claimID]
+	if len(c.Reviews) != 2 || c.Status != StatusDisputed {
+		t.Errorf("expected both reviews and the later status, got %+v", c)
+	}
+	ev := alice.Evidence[evID]
+	if ev.GitCommit != "fff999" || ev.LineRef != "20-22" || len(ev.History) != 3 {
+		t.Errorf("expected bob's later re-anchor and both in the history, got %+v", ev)
+	}
+	if ev.FilePath != "/home/alice/project/auth.go" {
+		t.Errorf("expected evidence to stay in alice's checkout, got %s", ev.FilePath)
+	}
+	if ev.LastCheck == nil || ev.LastCheck.Status != EvidenceValid {
+		t.Errorf("expected alice's own last check to be kept, got %+v", ev.LastCheck)
+	}
+
+	if again := alice.Merge(bob); again != (MergeStats{}) {
+		t.Errorf("expected merging again to change nothing, got %+v", again)
+	}
+}
+
+func TestMergeIsSymmetric(t *testing.T) {
+	alice, bob := mergeFixture(t)
+	a2, b2 := mergeFixture(t)
+	// IDs differ between fixtures, so nodes are compared by content.
+	alice.Merge(bob)
+	b2.Merge(a2)
+
+	shared := func(g *Graph) map[string]string {
+		out := map[string]string{}
+		for _, c := range g.Claims {
+			out["claim "+c.Content] = c.Status + " " + string(mustJSON(t, c.Reviews)) + " " + string(mustJSON(t, c.Tags))
+		}
+		for _, ev := range g.Evidence {
+			out["evidence "+ev.Path] = ev.GitCommit + " " + ev.LineRef + " " + string(mustJSON(t, sharedHistory(ev.History)))
+		}
+		return out
+	}
+	got, want := shared(b2), shared(alice)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d nodes either way, got %d", len(want), len(got))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %s, got %s", k, v, got[k])
+		}
+	}
+}
+
+func TestMergeEdgeKindConflict(t *testing.T) {
+	a, b := New(), New()
+	for _, g := range []*Graph{a, b} {
+		g.Claims["c1"] = &ClaimNode{ID: "c1", Status: StatusDraft}
+		g.Evidence["e1"] = &EvidenceNode{ID: "e1", FilePath: "/f.go", LineRef: "1"}
+	}
+	a.Edges = []Edge{{ClaimID: "c1", EvidenceID: "e1", Kind: EdgeSupports}}
+	b.Edges = []Edge{{ClaimID: "c1", EvidenceID: "e1", Kind: EdgeRefutes}}
+
+	a.Merge(b)
+	b.Merge(a)
+	if len(a.Edges) != 1 || a.Edges[0].Kind != b.Edges[0].Kind {
+		t.Errorf("expected both sides to settle on one kind, got %+v and %+v", a.Edges, b.Edges)
+	}
+
+	// A link repeated within the merged graph still makes one edge.
+	c := New()
+	c.Claims["c1"] = &ClaimNode{ID: "c1", Status: StatusDraft}
+	c.Evidence["e1"] = &EvidenceNode{ID: "e1", FilePath: "/f.go", LineRef: "1"}
+	c.Merge(&Graph{Edges: []Edge{
+		{ClaimID: "c1", EvidenceID: "e1", Kind: EdgeSupports},
+		{ClaimID: "c1", EvidenceID: "e1", Kind: EdgeContext},
+	}})
+	if len(c.Edges) != 1 {
+		t.Errorf("expected one edge for the repeated link, got %+v", c.Edges)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
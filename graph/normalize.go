@@ -0,0 +1,294 @@
+package graph
+
+import (
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// sourceToken is one semantic token of a file and the line it starts on.
+type sourceToken struct {
+	text string
+	line int
+}
+
+// tokenize splits src into semantic tokens. Go files are scanned with
+// go/scanner, dropping comments and automatically inserted semicolons; other
+// files are split on whitespace. Formatting-only edits therefore leave the
+// token stream unchanged.
+func tokenize(filePath string, src []byte) []sourceToken {
+	if !strings.HasSuffix(filePath, ".go") {
+		var tokens []sourceToken
+		for i, line := range strings.Split(string(src), "\n") {
+			for _, field := range strings.Fields(line) {
+				tokens = append(tokens, sourceToken{text: field, line: i + 1})
+			}
+		}
+		return tokens
+	}
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	var tokens []sourceToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return tokens
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		text := tok.String()
+		if lit != "" {
+			text = lit
+		}
+		tokens = append(tokens, sourceToken{text: text, line: fset.Position(pos).Line})
+	}
+}
+
+// tokensInRange returns the tokens starting on lines start through end.
+func tokensInRange(tokens []sourceToken, start, end int) []sourceToken {
+	var result []sourceToken
+	for _, t := range tokens {
+		if t.line >= start && t.line <= end {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// findTokens returns the index at which needle occurs contiguously in
+// haystack, comparing token text only, or -1 if it does not occur.
+func findTokens(haystack, needle []sourceToken) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j].text != needle[j].text {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxTokenEdits bounds the tokens inserted and removed between two versions
+// of a file that alignTokens will look through. Files further apart than
+// that are treated as changed rather than searched at length.
+const maxTokenEdits = 1000
+
+// alignTokens matches the tokens of a to those of b along a shortest edit
+// script (Myers' diff), so tokens are followed to where they sit in b rather
+// than to the first identical text. It returns, for each token of a,
+// the index of its match in b or -1 if it was removed or replaced; ok is
+// false if more than maxEdits insertions and removals separate a and b.
+func alignTokens(a, b []sourceToken, maxEdits int) (match []int, ok bool) {
+	match = make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre].text == b[pre].text {
+		match[pre] = pre
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf].text == b[len(b)-1-suf].text {
+		match[len(a)-1-suf] = len(b) - 1 - suf
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	n, m := len(a), len(b)
+
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+	// v[k+off] is the furthest x reached on diagonal k = x-y. trace[d] keeps
+	// diagonals -d-1 through d+1 of v as they were before step d.
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+off] < v[k+1+off]) {
+				x = v[k+1+off]
+			} else {
+				x = v[k-1+off] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x].text == b[y].text {
+				x++
+				y++
+			}
+			v[k+off] = x
+			if x < n || y < m {
+				continue
+			}
+			// Walk back through the steps, matching each diagonal run.
+			for ; d > 0; d-- {
+				prev := trace[d]
+				k := x - y
+				prevK := k - 1
+				if k == -d || (k != d && prev[k-1+d+1] < prev[k+1+d+1]) {
+					prevK = k + 1
+				}
+				prevX := prev[prevK+d+1]
+				prevY := prevX - prevK
+				for x > prevX && y > prevY {
+					x, y = x-1, y-1
+					match[pre+x] = pre + y
+				}
+				x, y = prevX, prevY
+			}
+			for x > 0 && y > 0 {
+				x, y = x-1, y-1
+				match[pre+x] = pre + y
+			}
+			return match, true
+		}
+	}
+	return match, false
+}
+
+// normalizedValidity checks whether each cited range survives, token for
+// token, between the recorded file and the file at HEAD, where alignTokens
+// places it (see followRange). Whitespace, comment and line-shift changes
+// keep the evidence valid; CurrentLineRef reports where the ranges sit now.
+func normalizedValidity(ev *EvidenceNode, old, head []byte) Validity {
+	ranges, err := ParseLineRef(ev.LineRef)
+	if err != nil {
+		return Validity{Valid: false}
+	}
+	oldTokens, headTokens := tokenize(ev.FilePath, old), tokenize(ev.FilePath, head)
+	match, ok := alignTokens(oldTokens, headTokens, maxTokenEdits)
+	if !ok {
+		return Validity{Valid: false}
+	}
+
+	var current []LineRange
+	for _, r := range ranges {
+		// Tokens first through last-1 start on the cited lines.
+		first := sort.Search(len(oldTokens), func(i int) bool { return oldTokens[i].line >= r.Start })
+		last := sort.Search(len(oldTokens), func(i int) bool { return oldTokens[i].line > r.End })
+		moved, ok := followRange(r, old, head, oldTokens, headTokens, match, first, last)
+		if !ok {
+			return Validity{Valid: false}
+		}
+		current = append(current, moved)
+	}
+	v := Validity{Valid: true}
+	if ref := FormatLineRef(current); ref != ev.LineRef {
+		v.CurrentLineRef = ref
+	}
+	return v
+}
+
+// followRange returns where the cited range r of old, holding old tokens
+// first through last-1, sits in head. The nearest tokens on either side that
+// the alignment kept bound where the citation can be: its tokens must occur
+// exactly once between them, so a citation is never matched to identical
+// code elsewhere, and one that could be either of two copies counts as
+// changed. A range without tokens, such as a comment, is matched by its
+// lines instead (see followLines).
+func followRange(r LineRange, old, head []byte, oldTokens, headTokens []sourceToken, match []int, first, last int) (LineRange, bool) {
+	before, after := first-1, last
+	for before >= 0 && match[before] < 0 {
+		before--
+	}
+	for after < len(oldTokens) && match[after] < 0 {
+		after++
+	}
+	lo, hi := 0, len(headTokens)
+	if before >= 0 {
+		lo = match[before] + 1
+	}
+	if after < len(oldTokens) {
+		hi = match[after]
+	}
+
+	if first == last {
+		// Bound the lines by the kept tokens, and take blank lines to move
+		// with the nearer of them.
+		loLine, hiLine, shift := 1, strings.Count(string(head), "\n")+1, 0
+		if before >= 0 {
+			loLine = headTokens[match[before]].line
+			shift = loLine - oldTokens[before].line
+		}
+		if after < len(oldTokens) {
+			hiLine = headTokens[match[after]].line
+			shift = hiLine - oldTokens[after].line
+		}
+		return followLines(r, old, head, loLine, hiLine, shift)
+	}
+
+	region, window := oldTokens[first:last], headTokens[lo:hi]
+	i := findTokens(window, region)
+	if i < 0 || findTokens(window[i+1:], region) >= 0 {
+		return LineRange{}, false
+	}
+	// Keep the cited span around the tokens, so blank or comment lines at
+	// either edge of the range move with them.
+	start := r.Start + window[i].line - region[0].line
+	end := r.End + window[i+len(region)-1].line - region[len(region)-1].line
+	return LineRange{Start: start, End: end}, true
+}
+
+// followLines finds the lines of range r of old, with whitespace collapsed,
+// exactly once among lines lo through hi of head. Such ranges hold no tokens,
+// so their text is all there is to compare: an edited comment invalidates
+// its citation. Blank lines are moved by shift and must still be blank.
+func followLines(r LineRange, old, head []byte, lo, hi, shift int) (LineRange, bool) {
+	collapse := func(src []byte) []string {
+		lines := strings.Split(string(src), "\n")
+		for i, line := range lines {
+			lines[i] = strings.Join(strings.Fields(line), " ")
+		}
+		return lines
+	}
+	oldLines, headLines := collapse(old), collapse(head)
+	if r.Start < 1 || r.End > len(oldLines) {
+		return LineRange{}, false
+	}
+	cited := oldLines[r.Start-1 : r.End]
+	blank := strings.Join(cited, "") == ""
+	if blank {
+		lo, hi = r.Start+shift, r.Start+shift
+	}
+
+	found := LineRange{}
+	for s := lo; s <= hi && s+len(cited)-1 <= len(headLines); s++ {
+		if s < 1 {
+			continue
+		}
+		match := true
+		for j, line := range cited {
+			if headLines[s-1+j] != line {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		if found.Start != 0 {
+			return LineRange{}, false
+		}
+		found = LineRange{Start: s, End: s + len(cited) - 1}
+	}
+	return found, found.Start != 0
+}
+
+// sameTokens reports whether a and b tokenize identically.
+func sameTokens(filePath, a, b string) bool {
+	ta, tb := tokenize(filePath, []byte(a)), tokenize(filePath, []byte(b))
+	return len(ta) == len(tb) && findTokens(ta, tb) == 0
+}
@@ -0,0 +1,185 @@
+package graph
+
+import "testing"
+
+func newNormalizeChecker(path, old, head string) *mockRevisionChecker {
+	return &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:" + path: true}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{
+			"abc123:" + path: old,
+			"HEAD:" + path:   head,
+		}},
+	}
+}
+
+func TestTokenizeGoIgnoresFormattingAndComments(t *testing.T) {
+	a := tokenize("a.go", []byte("package a\n\nfunc A(x int)   int {\n\treturn x+1 // one more\n}\n"))
+	b := tokenize("a.go", []byte("package a\n\n// A adds one.\nfunc A(x int) int {\n\treturn x + 1\n}\n"))
+
+	if len(a) != len(b) || findTokens(a, b) != 0 {
+		t.Errorf("expected identical token streams, got %v and %v", a, b)
+	}
+}
+
+func TestTokenizePlainTextCollapsesWhitespace(t *testing.T) {
+	a := tokenize("README.md", []byte("Run  the\tserver\n"))
+	b := tokenize("README.md", []byte("Run the server\n\n"))
+
+	if len(a) != 3 || len(a) != len(b) || findTokens(a, b) != 0 {
+		t.Errorf("expected identical token streams, got %v and %v", a, b)
+	}
+}
+
+func TestNormalizedValidityIgnoresReformatting(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/a.go", "3-5", "abc123")
+	checker := newNormalizeChecker("/home/user/a.go",
+		"package a\n\nfunc A(x int)   int {\n\treturn x+1\n}\n",
+		"package a\n\n// A adds one.\n// It never fails.\nfunc A(x int) int {\n\treturn x + 1 // simple\n}\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid {
+		t.Error("expected evidence to stay valid after formatting-only changes")
+	}
+	if v.CurrentLineRef != "5-7" {
+		t.Errorf("expected current line ref %q, got %q", "5-7", v.CurrentLineRef)
+	}
+
+	v, _ = g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if v.Valid {
+		t.Error("expected evidence to be invalid without normalization")
+	}
+}
+
+func TestNormalizedValidityDetectsSemanticChange(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/a.go", "3-5", "abc123")
+	checker := newNormalizeChecker("/home/user/a.go",
+		"package a\n\nfunc A(x int) int {\n\treturn x + 1\n}\n",
+		"package a\n\nfunc A(x int) int {\n\treturn x + 2\n}\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected evidence to be invalid after a semantic change")
+	}
+}
+
+func TestNormalizedValidityOnlyChecksCitedRanges(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/notes.txt", "1,3", "abc123")
+	checker := newNormalizeChecker("/home/user/notes.txt",
+		"alpha beta\nchanged later\ngamma\n",
+		"alpha   beta\nsomething else\n\ngamma\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid {
+		t.Error("expected evidence to be valid when only uncited lines changed")
+	}
+	if v.CurrentLineRef != "1,4" {
+		t.Errorf("expected current line ref %q, got %q", "1,4", v.CurrentLineRef)
+	}
+}
+
+func TestNormalizedValidityForSymbol(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/a.go", "3-5", "abc123")
+	ev.Symbol = "A"
+	checker := newNormalizeChecker("/home/user/a.go",
+		"package a\n\nfunc A(x int) int {\n\treturn x+1\n}\n",
+		"package a\n\nfunc A(x int) int {\n\t// One more than x.\n\treturn x + 1\n}\n")
+
+	v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if v.Valid {
+		t.Error("expected symbol evidence to be invalid without normalization")
+	}
+	v, _ = g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true})
+	if !v.Valid {
+		t.Error("expected symbol evidence to be valid with normalization")
+	}
+}
+
+func TestNormalizedValidityWithDuplicatedCode(t *testing.T) {
+	const old = "package a\n\nfunc A() error {\n\treturn nil\n}\n\nfunc B() error {\n\treturn nil\n}\n"
+	g := New()
+	a := g.AddEvidence("/home/user/a.go", "4", "abc123")
+	b := g.AddEvidence("/home/user/a.go", "8", "abc123")
+
+	// B's line changes; A's identical line must not stand in for it.
+	checker := newNormalizeChecker("/home/user/a.go", old,
+		"package a\n\nfunc A() error {\n\treturn nil\n}\n\nfunc B() error {\n\treturn errBoom\n}\n")
+	if v, _ := g.EvidenceValidity(b.ID, checker, CheckOptions{Normalize: true}); v.Valid {
+		t.Errorf("expected B's changed line to be invalid, got %+v", v)
+	}
+	if v, _ := g.EvidenceValidity(a.ID, checker, CheckOptions{Normalize: true}); !v.Valid || v.CurrentLineRef != "" {
+		t.Errorf("expected A's line to be valid where it was, got %+v", v)
+	}
+
+	// A's line changes and lines are added above; B is followed, not A.
+	checker = newNormalizeChecker("/home/user/a.go", old,
+		"package a\n\n// Errors.\n\nfunc A() error {\n\treturn errBoom\n}\n\nfunc B() error {\n\treturn nil\n}\n")
+	if v, _ := g.EvidenceValidity(b.ID, checker, CheckOptions{Normalize: true}); !v.Valid || v.CurrentLineRef != "10" {
+		t.Errorf("expected B's line to be valid at line 10, got %+v", v)
+	}
+	if v, _ := g.EvidenceValidity(a.ID, checker, CheckOptions{Normalize: true}); v.Valid {
+		t.Errorf("expected A's changed line to be invalid, got %+v", v)
+	}
+}
+
+func TestNormalizedValidityRejectsAmbiguousMatch(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/notes.txt", "2", "abc123")
+	checker := newNormalizeChecker("/home/user/notes.txt", "alpha\nbeta\ngamma\n", "alpha\nbeta\ndelta\nbeta\ngamma\n")
+
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true}); v.Valid {
+		t.Errorf("expected a citation that could be either copy to be invalid, got %+v", v)
+	}
+}
+
+func TestNormalizedValidityChecksCommentOnlyRange(t *testing.T) {
+	const old = "package a\n\n// A is one.\nconst A = 1\n"
+	g := New()
+	ev := g.AddEvidence("/home/user/a.go", "3", "abc123")
+
+	checker := newNormalizeChecker("/home/user/a.go", old, "package a\n\nimport \"os\"\n\n// A is one.\nconst A = 1\n")
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true}); !v.Valid || v.CurrentLineRef != "5" {
+		t.Errorf("expected the comment to move to line 5, got %+v", v)
+	}
+
+	checker = newNormalizeChecker("/home/user/a.go", old, "package a\n\n// A is   one.\n\nconst A = 1\n")
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true}); !v.Valid || v.CurrentLineRef != "" {
+		t.Errorf("expected a respaced comment to stay valid, got %+v", v)
+	}
+
+	checker = newNormalizeChecker("/home/user/a.go", old, "package a\n\n// A is the first constant.\nconst A = 1\n")
+	if v, _ := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true}); v.Valid {
+		t.Errorf("expected an edited comment to be invalid, got %+v", v)
+	}
+
+	blank := g.AddEvidence("/home/user/a.go", "2", "abc123")
+	checker = newNormalizeChecker("/home/user/a.go", old, "// Package a.\npackage a\n\n// A is one.\nconst A = 1\n")
+	if v, _ := g.EvidenceValidity(blank.ID, checker, CheckOptions{Normalize: true}); !v.Valid || v.CurrentLineRef != "3" {
+		t.Errorf("expected the blank line to move to line 3, got %+v", v)
+	}
+}
+
+func TestAlignTokensGivesUpPastEditLimit(t *testing.T) {
+	a := tokenize("a.txt", []byte("one two three four five"))
+	b := tokenize("a.txt", []byte("one 2 3 4 five"))
+
+	if _, ok := alignTokens(a, b, 5); ok {
+		t.Error("expected alignment to give up past 5 edits")
+	}
+	match, ok := alignTokens(a, b, 6)
+	if !ok || match[0] != 0 || match[1] != -1 || match[4] != 4 {
+		t.Errorf("expected one and five kept and the rest replaced, got %v", match)
+	}
+}
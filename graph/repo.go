@@ -0,0 +1,179 @@
+package graph
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Repo is a known repository: its identity, where it is checked out on this
+// machine, and the branch validity is usually judged against. A repository
+// learned from another machine's graph has no Path until its checkout here is
+// registered.
+type Repo struct {
+	ID            string    `json:"id"`
+	Path          string    `json:"path,omitempty"`
+	DefaultBranch string    `json:"default_branch,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RegisterRepo records a repository, or updates the checkout path and default
+// branch of a known one with any that are given. Returns an error if the ID
+// is empty, the path is not absolute, or a new repository has no path.
+func (g *Graph) RegisterRepo(id, path, defaultBranch string) (*Repo, error) {
+	if id == "" {
+		return nil, fmt.Errorf("repository id is required")
+	}
+	if path != "" && !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("repository path must be absolute")
+	}
+	repo, ok := g.Repos[id]
+	if !ok {
+		if path == "" {
+			return nil, fmt.Errorf("repository path is required")
+		}
+		repo = &Repo{ID: id, CreatedAt: time.Now()}
+		g.Repos[id] = repo
+	}
+	if path != "" {
+		repo.Path = path
+	}
+	if defaultBranch != "" {
+		repo.DefaultBranch = defaultBranch
+	}
+	return repo, nil
+}
+
+// ListRepos returns the known repositories sorted by ID.
+func (g *Graph) ListRepos() []*Repo {
+	repos := make([]*Repo, 0, len(g.Repos))
+	for _, r := range g.Repos {
+		repos = append(repos, r)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].ID < repos[j].ID })
+	return repos
+}
+
+// EvidenceUnder returns the evidence citing files inside dir, sorted by ID.
+func (g *Graph) EvidenceUnder(dir string) []*EvidenceNode {
+	var evidence []*EvidenceNode
+	for _, ev := range g.Evidence {
+		if within(dir, ev.FilePath) {
+			evidence = append(evidence, ev)
+		}
+	}
+	sort.Slice(evidence, func(i, j int) bool { return evidence[i].ID < evidence[j].ID })
+	return evidence
+}
+
+// ClaimCites reports whether any evidence linked to a claim cites one of
+// paths, or a file inside one of them.
+func (g *Graph) ClaimCites(claimID string, paths ...string) bool {
+	for _, ev := range g.GetEvidenceForClaim(claimID) {
+		for _, p := range paths {
+			if within(p, ev.FilePath) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// within reports whether path is dir or a file inside it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ResolveRepoPaths points evidence and registered repositories at local
+// checkouts: for each evidence node whose Repo is a key of checkouts,
+// FilePath becomes Path joined to that checkout directory. Returns the number
+// of evidence nodes whose FilePath changed.
+func (g *Graph) ResolveRepoPaths(checkouts map[string]string) int {
+	for id, dir := range checkouts {
+		if repo, ok := g.Repos[id]; ok {
+			repo.Path = dir
+		}
+	}
+	changed := 0
+	for _, ev := range g.Evidence {
+		dir, ok := checkouts[ev.Repo]
+		if !ok || ev.Repo == "" || ev.Path == "" {
+			continue
+		}
+		p := filepath.Join(dir, filepath.FromSlash(ev.Path))
+		if p != ev.FilePath {
+			ev.FilePath = p
+			changed++
+		}
+	}
+	return changed
+}
+
+// CheckoutPath returns where the file cited by repository-relative evidence
+// is in the registered checkout of its repository, or "" if the evidence has
+// no repository-relative path or its repository no checkout on this machine.
+func (g *Graph) CheckoutPath(ev *EvidenceNode) string {
+	if ev.Repo == "" || ev.Path == "" {
+		return ""
+	}
+	repo, ok := g.Repos[ev.Repo]
+	if !ok || repo.Path == "" {
+		return ""
+	}
+	return filepath.Join(repo.Path, filepath.FromSlash(ev.Path))
+}
+
+// CheckoutFile returns the absolute path of the file at relPath, a
+// slash-separated path from the root of repository repo, in the repository's
+// registered checkout. Returns an error if relPath is not a clean path inside
+// the repository or the repository has no checkout registered here.
+func (g *Graph) CheckoutFile(repo, relPath string) (string, error) {
+	if repo == "" || !isRepoPath(relPath) {
+		return "", fmt.Errorf("repo is required, and path must be a clean slash-separated path inside it")
+	}
+	r, ok := g.Repos[repo]
+	if !ok || r.Path == "" {
+		return "", fmt.Errorf("repository %s has no checkout registered here (register one with POST /repos)", repo)
+	}
+	return filepath.Join(r.Path, filepath.FromSlash(relPath)), nil
+}
+
+// isRepoPath reports whether p is a clean, relative, slash-separated path
+// that stays inside the directory it is relative to.
+func isRepoPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && path.Clean(p) == p && p != "." && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// errNoCheckout is returned for evidence that cannot be read because its
+// repository has no checkout on this machine.
+func errNoCheckout(ev *EvidenceNode) error {
+	return fmt.Errorf("evidence %q is in repository %s, which has no checkout registered here", ev.ID, ev.Repo)
+}
+
+// CheckoutRoot returns the checkout directory FilePath was resolved against,
+// or "" if the evidence has no repository-relative path.
+func (ev *EvidenceNode) CheckoutRoot() string {
+	if ev.Repo == "" || ev.Path == "" {
+		return ""
+	}
+	suffix := string(filepath.Separator) + filepath.FromSlash(ev.Path)
+	if !strings.HasSuffix(ev.FilePath, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(ev.FilePath, suffix)
+}
+
+// moveTo points the evidence at filePath, keeping Path in step when the new
+// file is in the same checkout.
+func (ev *EvidenceNode) moveTo(filePath string) {
+	if root := ev.CheckoutRoot(); root != "" {
+		if rel, err := filepath.Rel(root, filePath); err == nil && !strings.HasPrefix(rel, "..") {
+			ev.Path = filepath.ToSlash(rel)
+		}
+	}
+	ev.FilePath = filePath
+}
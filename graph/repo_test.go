@@ -0,0 +1,152 @@
+package graph
+
+import "testing"
+
+func TestResolveRepoPaths(t *testing.T) {
+	g := New()
+	mapped := g.AddEvidence("/home/alice/project/pkg/auth.go", "1", "abc123")
+	mapped.Repo, mapped.Path = "root1", "pkg/auth.go"
+	unmapped := g.AddEvidence("/home/alice/other/main.go", "1", "abc123")
+	unmapped.Repo, unmapped.Path = "root2", "main.go"
+	legacy := g.AddEvidence("/home/alice/legacy.go", "1", "abc123")
+
+	changed := g.ResolveRepoPaths(map[string]string{"root1": "/home/bob/src/project"})
+
+	if changed != 1 {
+		t.Errorf("expected 1 evidence node to change, got %d", changed)
+	}
+	if mapped.FilePath != "/home/bob/src/project/pkg/auth.go" {
+		t.Errorf("expected file path %q, got %q", "/home/bob/src/project/pkg/auth.go", mapped.FilePath)
+	}
+	if unmapped.FilePath != "/home/alice/other/main.go" || legacy.FilePath != "/home/alice/legacy.go" {
+		t.Error("expected evidence outside mapped repositories to be unchanged")
+	}
+}
+
+func TestReanchorKeepsRepoPath(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "1", "abc123")
+	ev.Repo, ev.Path = "root1", "old.go"
+
+	if _, err := g.Reanchor(ev.ID, newRenameChecker("x\n", "x\n"), CheckOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev.FilePath != "/home/user/new.go" || ev.Path != "new.go" {
+		t.Errorf("expected %q at repo path %q, got %q at %q", "/home/user/new.go", "new.go", ev.FilePath, ev.Path)
+	}
+}
+
+func TestRegisterRepo(t *testing.T) {
+	g := New()
+
+	repo, err := g.RegisterRepo("root1", "/home/user/project", "origin/main")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.Path != "/home/user/project" || repo.DefaultBranch != "origin/main" || repo.CreatedAt.IsZero() {
+		t.Errorf("unexpected repo %+v", repo)
+	}
+
+	updated, err := g.RegisterRepo("root1", "/home/user/src/project", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated != repo || repo.Path != "/home/user/src/project" || repo.DefaultBranch != "origin/main" {
+		t.Errorf("expected path updated and branch kept, got %+v", repo)
+	}
+
+	for _, tt := range []struct{ id, path string }{{"", "/home/user/x"}, {"root2", ""}, {"root2", "relative"}} {
+		if _, err := g.RegisterRepo(tt.id, tt.path, ""); err == nil {
+			t.Errorf("expected error registering %q at %q", tt.id, tt.path)
+		}
+	}
+}
+
+func TestListRepos(t *testing.T) {
+	g := New()
+	g.RegisterRepo("b", "/home/user/b", "")
+	g.RegisterRepo("a", "/home/user/a", "")
+
+	repos := g.ListRepos()
+	if len(repos) != 2 || repos[0].ID != "a" || repos[1].ID != "b" {
+		t.Errorf("expected repos sorted by ID, got %v", repos)
+	}
+}
+
+func TestResolveRepoPathsUpdatesRegistry(t *testing.T) {
+	g := New()
+	repo, _ := g.RegisterRepo("root1", "/home/alice/project", "")
+
+	g.ResolveRepoPaths(map[string]string{"root1": "/home/bob/src/project"})
+
+	if repo.Path != "/home/bob/src/project" {
+		t.Errorf("expected registry path %q, got %q", "/home/bob/src/project", repo.Path)
+	}
+}
+
+func TestCheckoutFile(t *testing.T) {
+	g := New()
+	g.RegisterRepo("root1", "/home/bob/src/project", "")
+
+	p, err := g.CheckoutFile("root1", "pkg/auth.go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p != "/home/bob/src/project/pkg/auth.go" {
+		t.Errorf("expected %q, got %q", "/home/bob/src/project/pkg/auth.go", p)
+	}
+
+	for _, tt := range []struct{ repo, path string }{
+		{"root2", "pkg/auth.go"}, {"", "pkg/auth.go"}, {"root1", ""}, {"root1", "/etc/passwd"},
+		{"root1", "../other/x.go"}, {"root1", "pkg/../auth.go"}, {"root1", "."},
+	} {
+		if _, err := g.CheckoutFile(tt.repo, tt.path); err == nil {
+			t.Errorf("expected error resolving %q in %q", tt.path, tt.repo)
+		}
+	}
+}
+
+func TestEvidenceUnder(t *testing.T) {
+	g := New()
+	inside := g.AddEvidence("/home/alice/project/pkg/auth.go", "1", "abc123")
+	top := g.AddEvidence("/home/alice/project/main.go", "1", "abc123")
+	g.AddEvidence("/home/alice/project2/main.go", "1", "abc123")
+	g.AddEvidence("/home/alice/other.go", "1", "abc123")
+
+	got := g.EvidenceUnder("/home/alice/project")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 evidence nodes, got %d", len(got))
+	}
+	for _, ev := range got {
+		if ev != inside && ev != top {
+			t.Errorf("unexpected evidence %s", ev.FilePath)
+		}
+	}
+	if got[0].ID > got[1].ID {
+		t.Error("expected evidence sorted by ID")
+	}
+}
+
+func TestClaimCites(t *testing.T) {
+	g := New()
+	claim := g.AddClaim("auth checks tokens")
+	ev := g.AddEvidence("/home/alice/project/pkg/auth.go", "1", "abc123")
+	g.LinkEvidence(claim.ID, ev.ID)
+	g.AddEvidence("/home/alice/project/main.go", "1", "abc123")
+
+	tests := []struct {
+		paths []string
+		cites bool
+	}{
+		{[]string{"/home/alice/project/pkg/auth.go"}, true},
+		{[]string{"/home/alice/project/main.go", "/home/alice/project/pkg"}, true},
+		{[]string{"/home/alice/project/main.go"}, false},
+		{[]string{"/home/alice/project/pkg/auth.go.orig"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := g.ClaimCites(claim.ID, tt.paths...); got != tt.cites {
+			t.Errorf("paths %v: expected cites=%v, got %v", tt.paths, tt.cites, got)
+		}
+	}
+}
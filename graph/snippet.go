@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// SnippetLine is one cited line of a file.
+type SnippetLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// Snippet holds the cited lines of an evidence node as they read at the
+// recorded commit and at HEAD, so drift can be seen side by side.
+type Snippet struct {
+	AtCommit []SnippetLine `json:"at_commit"`
+	AtHead   []SnippetLine `json:"at_head,omitempty"`
+}
+
+// GetSnippet reads the cited lines of an evidence node at its recorded commit
+// and at HEAD, following renames if the reader can track them. Returns an
+// error if the evidence is not found or the file cannot be read at the
+// recorded commit; AtHead is left empty when the file cannot be read at HEAD.
+func (g *Graph) GetSnippet(id string, reader RevisionReader) (*Snippet, error) {
+	ev, ok := g.Evidence[id]
+	if !ok {
+		return nil, fmt.Errorf("evidence %q not found", id)
+	}
+	if ev.FilePath == "" {
+		return nil, errNoCheckout(ev)
+	}
+	ranges, err := ParseLineRef(ev.LineRef)
+	if err != nil {
+		return nil, err
+	}
+	old, err := reader.ReadFileAt(ev.GitCommit, ev.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	snippet := &Snippet{AtCommit: selectLines(old, ranges)}
+	headPath := ev.FilePath
+	if tracker, ok := reader.(RenameTracker); ok {
+		if p, err := tracker.CurrentPath(ev.GitCommit, ev.FilePath); err == nil {
+			headPath = p
+		}
+	}
+	if head, err := reader.ReadFileAt("HEAD", headPath); err == nil {
+		// A symbol is read wherever it currently sits in the file.
+		if start, end, _, err := symbolSource(head, ev.Symbol); ev.Symbol != "" && err == nil {
+			ranges = []LineRange{{Start: start, End: end}}
+		}
+		snippet.AtHead = selectLines(head, ranges)
+	}
+	return snippet, nil
+}
+
+// selectLines returns the lines of content within ranges, skipping any that
+// lie past the end of the file.
+func selectLines(content []byte, ranges []LineRange) []SnippetLine {
+	lines := strings.Split(string(bytes.TrimSuffix(content, []byte("\n"))), "\n")
+	result := []SnippetLine{}
+	for _, r := range ranges {
+		for n := r.Start; n <= r.End && n <= len(lines); n++ {
+			result = append(result, SnippetLine{Number: n, Text: lines[n-1]})
+		}
+	}
+	return result
+}
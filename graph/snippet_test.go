@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+)
+
+// mockRevisionReader serves file contents keyed by "rev:filepath".
+type mockRevisionReader struct {
+	files map[string]string
+}
+
+func (m *mockRevisionReader) ReadFileAt(rev, filePath string) ([]byte, error) {
+	content, ok := m.files[rev+":"+filePath]
+	if !ok {
+		return nil, fmt.Errorf("path %q does not exist in %q", filePath, rev)
+	}
+	return []byte(content), nil
+}
+
+func TestGetSnippet(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "2-3,5", "abc123")
+
+	reader := &mockRevisionReader{files: map[string]string{
+		"abc123:/home/user/auth.go": "one\ntwo\nthree\nfour\nfive\n",
+		"HEAD:/home/user/auth.go":   "one\nTWO\nthree\nfour\n",
+	}}
+
+	snippet, err := g.GetSnippet(ev.ID, reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []SnippetLine{{2, "two"}, {3, "three"}, {5, "five"}}
+	if fmt.Sprint(snippet.AtCommit) != fmt.Sprint(want) {
+		t.Errorf("expected lines at commit %v, got %v", want, snippet.AtCommit)
+	}
+	want = []SnippetLine{{2, "TWO"}, {3, "three"}}
+	if fmt.Sprint(snippet.AtHead) != fmt.Sprint(want) {
+		t.Errorf("expected lines at HEAD %v, got %v", want, snippet.AtHead)
+	}
+}
+
+func TestGetSnippetFileMissingAtHead(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "1", "abc123")
+
+	reader := &mockRevisionReader{files: map[string]string{
+		"abc123:/home/user/auth.go": "package auth\n",
+	}}
+
+	snippet, err := g.GetSnippet(ev.ID, reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(snippet.AtCommit) != 1 || snippet.AtCommit[0].Text != "package auth" {
+		t.Errorf("unexpected lines at commit %v", snippet.AtCommit)
+	}
+	if snippet.AtHead != nil {
+		t.Errorf("expected no lines at HEAD, got %v", snippet.AtHead)
+	}
+}
+
+func TestGetSnippetErrors(t *testing.T) {
+	g := New()
+	reader := &mockRevisionReader{files: map[string]string{}}
+
+	if _, err := g.GetSnippet("nonexistent", reader); err == nil {
+		t.Error("expected error for nonexistent evidence")
+	}
+
+	ev := g.AddEvidence("/home/user/auth.go", "1-3", "abc123")
+	if _, err := g.GetSnippet(ev.ID, reader); err == nil {
+		t.Error("expected error when file is missing at commit")
+	}
+
+	ev = g.AddEvidence("/home/user/auth.go", "1", "abc123")
+	ev.LineRef = "abc"
+	reader.files["abc123:/home/user/auth.go"] = "package auth\n"
+	if _, err := g.GetSnippet(ev.ID, reader); err == nil {
+		t.Error("expected error for unparseable line reference")
+	}
+}
+
+func TestGetSnippetFollowsSymbol(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "3", "abc123")
+	ev.Symbol = "A"
+
+	reader := &mockRevisionReader{files: map[string]string{
+		"abc123:/home/user/auth.go": "package auth\n\nfunc A() {}\n",
+		"HEAD:/home/user/auth.go":   "package auth\n\nfunc B() {}\n\nfunc A() {}\n",
+	}}
+
+	snippet, err := g.GetSnippet(ev.ID, reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []SnippetLine{{5, "func A() {}"}}
+	if fmt.Sprint(snippet.AtHead) != fmt.Sprint(want) {
+		t.Errorf("expected lines at HEAD %v, got %v", want, snippet.AtHead)
+	}
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// symbolSource locates a top-level Go function, type or method in src and
+// returns its line range and source text. The symbol is written as Name or
+// Type.Method, optionally qualified by the package path or name, e.g.
+// "trees/graph.Graph.AddEvidence" or "graph.New".
+func symbolSource(src []byte, symbol string) (start, end int, text string, err error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("parsing Go source: %v", err)
+	}
+
+	parts := strings.Split(symbol[strings.LastIndex(symbol, "/")+1:], ".")
+	if len(parts) > 1 && parts[0] == file.Name.Name {
+		parts = parts[1:]
+	}
+	if len(parts) > 2 {
+		return 0, 0, "", fmt.Errorf("symbol %q is not in package %s", symbol, file.Name.Name)
+	}
+
+	node := findDecl(file, parts)
+	if node == nil {
+		return 0, 0, "", fmt.Errorf("symbol %q not found", symbol)
+	}
+	from, to := fset.Position(node.Pos()), fset.Position(node.End())
+	return from.Line, to.Line, string(src[from.Offset:to.Offset]), nil
+}
+
+// findDecl returns the declaration of a function or type (one part) or a
+// method (two parts: receiver type and method name).
+func findDecl(file *ast.File, parts []string) ast.Node {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Name.Name != parts[len(parts)-1] {
+				continue
+			}
+			if len(parts) == 1 && d.Recv == nil {
+				return d
+			}
+			if len(parts) == 2 && d.Recv != nil && len(d.Recv.List) == 1 && receiverName(d.Recv.List[0].Type) == parts[0] {
+				return d
+			}
+		case *ast.GenDecl:
+			if len(parts) != 1 || d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				if ts := spec.(*ast.TypeSpec); ts.Name.Name == parts[0] {
+					if len(d.Specs) == 1 {
+						return d
+					}
+					return ts
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// receiverName returns the base type name of a method receiver, stripping
+// pointers and type parameters.
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
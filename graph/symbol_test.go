@@ -0,0 +1,124 @@
+package graph
+
+import "testing"
+
+const symbolTestSource = `package auth
+
+// Token is a bearer token.
+type Token struct {
+	Value string
+}
+
+type (
+	Claims map[string]string
+	Scope  string
+)
+
+// Validate checks the token.
+func Validate(t Token) bool {
+	return t.Value != ""
+}
+
+func (t *Token) Expired() bool {
+	return false
+}
+
+func (s Set[K]) Has(k K) bool {
+	return true
+}
+`
+
+func TestSymbolSource(t *testing.T) {
+	tests := []struct {
+		symbol     string
+		start, end int
+	}{
+		{"Validate", 14, 16},
+		{"auth.Validate", 14, 16},
+		{"example.com/app/auth.Validate", 14, 16},
+		{"Token", 4, 6},
+		{"Token.Expired", 18, 20},
+		{"auth.Token.Expired", 18, 20},
+		{"Scope", 10, 10},
+		{"Set.Has", 22, 24},
+	}
+	for _, tt := range tests {
+		start, end, _, err := symbolSource([]byte(symbolTestSource), tt.symbol)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.symbol, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("%s: expected lines %d-%d, got %d-%d", tt.symbol, tt.start, tt.end, start, end)
+		}
+	}
+}
+
+func TestSymbolSourceText(t *testing.T) {
+	_, _, text, err := symbolSource([]byte(symbolTestSource), "Validate")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "func Validate(t Token) bool {\n\treturn t.Value != \"\"\n}"
+	if text != want {
+		t.Errorf("expected text %q, got %q", want, text)
+	}
+}
+
+func TestSymbolSourceNotFound(t *testing.T) {
+	for _, symbol := range []string{"Missing", "Validate.Expired", "Expired", "other.pkg.Token.Expired", "Token.Missing"} {
+		if _, _, _, err := symbolSource([]byte(symbolTestSource), symbol); err == nil {
+			t.Errorf("%s: expected error", symbol)
+		}
+	}
+}
+
+func TestSymbolSourceInvalidGo(t *testing.T) {
+	if _, _, _, err := symbolSource([]byte("not go"), "Validate"); err == nil {
+		t.Error("expected error for invalid Go source")
+	}
+}
+
+func TestAddSymbolEvidence(t *testing.T) {
+	g := New()
+	reader := &mockRevisionReader{files: map[string]string{
+		"abc123:/home/user/auth.go": symbolTestSource,
+	}}
+
+	ev, err := g.AddSymbolEvidence("/home/user/auth.go", "auth.Validate", "abc123", reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev.LineRef != "14-16" {
+		t.Errorf("expected line ref %q, got %q", "14-16", ev.LineRef)
+	}
+	if ev.Symbol != "auth.Validate" {
+		t.Errorf("expected symbol %q, got %q", "auth.Validate", ev.Symbol)
+	}
+	if g.GetEvidence(ev.ID) == nil {
+		t.Error("expected evidence to be stored in graph")
+	}
+}
+
+func TestAddSymbolEvidenceErrors(t *testing.T) {
+	g := New()
+	reader := &mockRevisionReader{files: map[string]string{
+		"abc123:/home/user/auth.go": symbolTestSource,
+	}}
+
+	if _, err := g.AddSymbolEvidence("auth.go", "Validate", "abc123", reader); err == nil {
+		t.Error("expected error for relative path")
+	}
+	if _, err := g.AddSymbolEvidence("/home/user/auth.go", "Validate", "", reader); err == nil {
+		t.Error("expected error for empty commit")
+	}
+	if _, err := g.AddSymbolEvidence("/home/user/auth.go", "Validate", "def456", reader); err == nil {
+		t.Error("expected error for unreadable file")
+	}
+	if _, err := g.AddSymbolEvidence("/home/user/auth.go", "Missing", "abc123", reader); err == nil {
+		t.Error("expected error for missing symbol")
+	}
+	if len(g.Evidence) != 0 {
+		t.Errorf("expected no evidence, got %d", len(g.Evidence))
+	}
+}
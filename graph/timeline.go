@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kinds of timeline events.
+const (
+	EventCreated    = "created"
+	EventStatus     = "status"
+	EventReanchored = "reanchored"
+)
+
+// TimelineEvent is one entry in the history of an evidence node: its
+// creation, a change of status found by a check, or a re-anchor.
+type TimelineEvent struct {
+	Kind string    `json:"kind"`
+	At   time.Time `json:"at"`
+	// Commit is the commit cited at creation, the commit a status was found
+	// at, or the commit evidence was re-anchored to.
+	Commit string `json:"commit"`
+	// Status and PrevStatus are the new and previous status of a status
+	// change. PrevStatus is empty for the first check.
+	Status     string `json:"status,omitempty"`
+	PrevStatus string `json:"prev_status,omitempty"`
+	// FilePath and LineRef are where a re-anchor moved the citation, or
+	// where it was created. The Prev fields are where it was before a
+	// re-anchor.
+	FilePath     string `json:"file_path,omitempty"`
+	LineRef      string `json:"line_ref,omitempty"`
+	PrevCommit   string `json:"prev_commit,omitempty"`
+	PrevFilePath string `json:"prev_file_path,omitempty"`
+	PrevLineRef  string `json:"prev_line_ref,omitempty"`
+}
+
+// Timeline returns the history of an evidence node in the order it
+// happened, starting with its creation. Returns an error if the evidence is
+// not found.
+func (g *Graph) Timeline(id string) ([]TimelineEvent, error) {
+	ev, ok := g.Evidence[id]
+	if !ok {
+		return nil, fmt.Errorf("evidence %q not found", id)
+	}
+	created := TimelineEvent{
+		Kind:     EventCreated,
+		At:       ev.CreatedAt,
+		Commit:   ev.GitCommit,
+		FilePath: ev.FilePath,
+		LineRef:  ev.LineRef,
+	}
+	// The creation event shows where the citation first pointed, which
+	// the first re-anchor remembers.
+	for _, e := range ev.History {
+		if e.Kind == EventReanchored {
+			created.Commit, created.FilePath, created.LineRef = e.PrevCommit, e.PrevFilePath, e.PrevLineRef
+			break
+		}
+	}
+	return append([]TimelineEvent{created}, ev.History...), nil
+}
@@ -0,0 +1,52 @@
+package graph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "1", "abc123")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	g.RecordCheck(ev.ID, EvidenceValid, "head1", t0)
+	g.RecordCheck(ev.ID, EvidenceValid, "head2", t0.Add(time.Hour))
+	if _, err := g.Reanchor(ev.ID, newRenameChecker("x\n", "x\n"), CheckOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.RecordCheck(ev.ID, EvidenceStale, "head3", t0.Add(2*time.Hour))
+	if _, err := g.Reanchor(ev.ID, newRenameChecker("x\n", "x\n"), CheckOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	timeline, err := g.Timeline(ev.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(timeline) != 6 {
+		t.Fatalf("expected 6 events, got %d: %+v", len(timeline), timeline)
+	}
+
+	created := timeline[0]
+	if created.Kind != EventCreated || created.Commit != "abc123" || created.FilePath != "/home/user/old.go" || created.LineRef != "1" {
+		t.Errorf("expected creation at abc123 in old.go, got %+v", created)
+	}
+	if e := timeline[1]; e.Kind != EventStatus || e.Status != EvidenceValid || e.PrevStatus != "" || e.Commit != "head1" {
+		t.Errorf("expected first check valid at head1, got %+v", e)
+	}
+	if e := timeline[2]; e.Kind != EventReanchored || e.Commit != "def456" || e.FilePath != "/home/user/new.go" ||
+		e.PrevCommit != "abc123" || e.PrevFilePath != "/home/user/old.go" {
+		t.Errorf("expected re-anchor from old.go at abc123 to new.go at def456, got %+v", e)
+	}
+	if e := timeline[3]; e.Status != EvidenceStale || e.PrevStatus != EvidenceValid || e.Commit != "head3" || !e.At.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("expected stale at head3 after valid, got %+v", e)
+	}
+	if e := timeline[5]; e.Status != EvidenceValid || e.PrevStatus != EvidenceStale || e.Commit != "def456" {
+		t.Errorf("expected re-anchored evidence to be valid again at def456, got %+v", e)
+	}
+
+	if _, err := g.Timeline("nonexistent"); err == nil {
+		t.Error("expected error for nonexistent evidence")
+	}
+}
@@ -0,0 +1,516 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Evidence statuses distinguish why evidence is or is not valid.
+const (
+	EvidenceValid   = "valid"
+	EvidenceStale   = "stale"
+	EvidenceDeleted = "deleted"
+	// EvidenceDirty is committed evidence whose cited lines have uncommitted
+	// edits in the index or working tree. It is still valid.
+	EvidenceDirty = "dirty"
+	// EvidenceNotInHistory is evidence checked as of a commit that does not
+	// contain the commit it cites, so it did not exist yet there.
+	EvidenceNotInHistory = "not_in_history"
+	// EvidenceNoCheckout is evidence in a repository with no checkout
+	// registered on this machine, so there is nothing to check it against.
+	EvidenceNoCheckout = "no_checkout"
+)
+
+// Validity is the detailed result of checking an evidence node.
+type Validity struct {
+	Valid  bool   `json:"valid"`
+	Status string `json:"status"`
+	// DeletedIn is the commit that deleted the cited file.
+	DeletedIn string `json:"deleted_in,omitempty"`
+	// CurrentPath is where the cited file lives at HEAD when it has been
+	// renamed or moved since the recorded commit.
+	CurrentPath string `json:"current_path,omitempty"`
+	// CurrentLineRef is where the citation sits at HEAD when it has moved
+	// away from LineRef.
+	CurrentLineRef string `json:"current_line_ref,omitempty"`
+	// Ref and RefCommit name the ref checked against in place of HEAD and
+	// the commit it resolved to in the evidence's repository.
+	Ref       string `json:"ref,omitempty"`
+	RefCommit string `json:"ref_commit,omitempty"`
+}
+
+// CheckRecord is the stored outcome of checking an evidence node: its status,
+// the commit it was checked against, and when the status last changed.
+type CheckRecord struct {
+	Status    string    `json:"status"`
+	Commit    string    `json:"commit,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// RecordCheck stores the status an evidence node was found in at commit as
+// of at. ChangedAt moves, and the change is added to the node's history,
+// only when the status differs from the last one recorded. Returns whether
+// the status changed, or an error if the evidence is not found.
+func (g *Graph) RecordCheck(id, status, commit string, at time.Time) (bool, error) {
+	ev, ok := g.Evidence[id]
+	if !ok {
+		return false, fmt.Errorf("evidence %q not found", id)
+	}
+	record := CheckRecord{Status: status, Commit: commit, CheckedAt: at, ChangedAt: at}
+	if ev.LastCheck != nil && ev.LastCheck.Status == status {
+		record.ChangedAt = ev.LastCheck.ChangedAt
+		ev.LastCheck = &record
+		return false, nil
+	}
+	event := TimelineEvent{Kind: EventStatus, At: at, Commit: commit, Status: status}
+	if ev.LastCheck != nil {
+		event.PrevStatus = ev.LastCheck.Status
+	}
+	ev.History = append(ev.History, event)
+	ev.LastCheck = &record
+	return true, nil
+}
+
+// CheckOptions adjust how evidence validity is computed.
+type CheckOptions struct {
+	// Normalize ignores changes that leave the cited region's tokens intact,
+	// such as reformatting, comment edits and lines shifting. It requires a
+	// checker that can read file contents.
+	Normalize bool
+	// WorkingTree also reports valid evidence as dirty when its cited lines
+	// are modified locally. It requires a WorkingTreeChecker, and cannot be
+	// combined with Ref, since local edits are made against HEAD.
+	WorkingTree bool
+	// Ref checks against a branch, tag or commit instead of HEAD. It
+	// requires a RefScoper that is also a HeadResolver.
+	Ref string
+	// AsOf treats Ref as a point in history: evidence citing a commit Ref
+	// does not contain is reported as not in history rather than checked.
+	// Without an AncestryChecker every commit is assumed to be contained.
+	AsOf bool
+}
+
+// ScopeChecker returns checker scoped to ref, or checker itself when ref is
+// empty. Returns an error if the checker cannot be scoped.
+func ScopeChecker(checker GitChecker, ref string) (GitChecker, error) {
+	if ref == "" {
+		return checker, nil
+	}
+	scoper, ok := Capability[RefScoper](checker)
+	if !ok {
+		return nil, fmt.Errorf("checking against a ref is not supported by this checker")
+	}
+	return scoper.AtRef(ref), nil
+}
+
+// EvidenceValidity checks whether an evidence node is still valid. When the
+// checker supports it, renamed files are followed to their current path, and
+// evidence anchored on a Go symbol stays valid while the symbol's source is
+// unchanged even if the rest of the file changed. With opts.Ref set, HEAD is
+// replaced by that ref throughout, and with opts.AsOf also set, evidence
+// recorded after that ref is reported as not in history. Returns an error if
+// the evidence ID is not found, the ref cannot be resolved or is given along
+// with opts.WorkingTree, or the git check fails.
+func (g *Graph) EvidenceValidity(id string, checker GitChecker, opts CheckOptions) (Validity, error) {
+	ev, ok := g.Evidence[id]
+	if !ok {
+		return Validity{}, fmt.Errorf("evidence %q not found", id)
+	}
+	if opts.WorkingTree && opts.Ref != "" {
+		return Validity{}, fmt.Errorf("uncommitted edits can only be checked against HEAD, not %s", opts.Ref)
+	}
+	if ev.FilePath == "" {
+		return Validity{Status: EvidenceNoCheckout}, nil
+	}
+	var refCommit string
+	if opts.Ref != "" {
+		var err error
+		if checker, err = ScopeChecker(checker, opts.Ref); err != nil {
+			return Validity{}, err
+		}
+		if refCommit, err = resolveRef(ev, checker); err != nil {
+			return Validity{}, err
+		}
+		if opts.AsOf {
+			inHistory, err := containsCommit(ev, refCommit, checker)
+			if err != nil {
+				return Validity{}, err
+			}
+			if !inHistory {
+				return Validity{Status: EvidenceNotInHistory, Ref: opts.Ref, RefCommit: refCommit}, nil
+			}
+		}
+	}
+	var v Validity
+	var err error
+	if cache, ok := checker.(ValidityCache); ok {
+		v, err = cache.CachedValidity(ev, opts)
+	} else {
+		v, err = evidenceValidity(ev, checker, opts)
+	}
+	if err != nil {
+		return Validity{}, err
+	}
+	v.Ref, v.RefCommit = opts.Ref, refCommit
+	switch {
+	case v.Valid && opts.WorkingTree && isDirty(ev, v, checker):
+		v.Status = EvidenceDirty
+	case v.Valid:
+		v.Status = EvidenceValid
+	case v.DeletedIn != "":
+		v.Status = EvidenceDeleted
+	default:
+		v.Status = EvidenceStale
+	}
+	return v, nil
+}
+
+// CheckEvidenceByRepo checks the evidence nodes with the given IDs
+// checkout by checkout. Without opts.Ref, HEAD is resolved once for each
+// checkout and all of its evidence is checked against that commit, so one
+// validation sees each repository at a single commit even if HEAD moves
+// meanwhile. Each node is checked once however often it is listed. Nodes
+// whose check fails are left out of the validities and have an error
+// instead.
+func (g *Graph) CheckEvidenceByRepo(ids []string, checker GitChecker, opts CheckOptions) (map[string]Validity, map[string]error) {
+	groups := map[string][]*EvidenceNode{}
+	var order []string
+	for _, id := range ids {
+		ev, ok := g.Evidence[id]
+		if !ok {
+			continue
+		}
+		key := ev.CheckoutRoot()
+		if key == "" {
+			key = filepath.Dir(ev.FilePath)
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], ev)
+	}
+
+	validity := make(map[string]Validity, len(ids))
+	errs := map[string]error{}
+	for _, id := range ids {
+		if _, ok := g.Evidence[id]; !ok {
+			errs[id] = fmt.Errorf("evidence %q not found", id)
+		}
+	}
+	for _, key := range order {
+		repoChecker := pinHead(groups[key], checker, opts)
+		for _, ev := range groups[key] {
+			if _, done := validity[ev.ID]; done || errs[ev.ID] != nil {
+				continue
+			}
+			v, err := g.EvidenceValidity(ev.ID, repoChecker, opts)
+			if err != nil {
+				errs[ev.ID] = err
+				continue
+			}
+			validity[ev.ID] = v
+		}
+	}
+	return validity, errs
+}
+
+// pinHead returns checker scoped to the commit HEAD is at in the repository
+// of evidence, or checker itself when opts names a ref or the checker cannot
+// resolve and scope to commits.
+func pinHead(evidence []*EvidenceNode, checker GitChecker, opts CheckOptions) GitChecker {
+	if opts.Ref != "" {
+		return checker
+	}
+	for _, ev := range evidence {
+		if ev.FilePath == "" {
+			continue
+		}
+		head, err := resolveRef(ev, checker)
+		if err != nil {
+			break
+		}
+		if pinned, err := ScopeChecker(checker, head); err == nil {
+			return pinned
+		}
+		break
+	}
+	return checker
+}
+
+func evidenceValidity(ev *EvidenceNode, checker GitChecker, opts CheckOptions) (Validity, error) {
+	var v Validity
+	headPath := ev.FilePath
+	if tracker, ok := Capability[RenameTracker](checker); ok {
+		if p, err := tracker.CurrentPath(ev.GitCommit, ev.FilePath); err == nil && p != ev.FilePath {
+			headPath = p
+			v.CurrentPath = p
+		}
+	}
+
+	if tracker, ok := Capability[DeletionTracker](checker); ok && headPath == ev.FilePath {
+		deleting, err := tracker.DeletingCommit(ev.GitCommit, ev.FilePath)
+		if err != nil {
+			return Validity{}, err
+		}
+		if deleting != "" {
+			v.DeletedIn = deleting
+			return v, nil
+		}
+	}
+
+	// History on the recorded path says nothing about a renamed file, so
+	// those are always compared by content below.
+	if headPath == ev.FilePath {
+		changed, err := checker.HasFileChangedSince(ev.GitCommit, ev.FilePath)
+		if err != nil {
+			return Validity{}, err
+		}
+		if !changed {
+			v.Valid = true
+			return v, nil
+		}
+	}
+
+	reader, ok := Capability[RevisionReader](checker)
+	if !ok || (ev.Symbol == "" && !opts.Normalize && headPath == ev.FilePath) {
+		return v, nil
+	}
+	old, err := reader.ReadFileAt(ev.GitCommit, ev.FilePath)
+	if err != nil {
+		return v, nil
+	}
+	head, err := reader.ReadFileAt("HEAD", headPath)
+	if err != nil {
+		return v, nil
+	}
+
+	var content Validity
+	switch {
+	case ev.Symbol != "":
+		content = symbolValidity(ev, old, head, opts)
+	case opts.Normalize:
+		content = normalizedValidity(ev, old, head)
+	default:
+		content = Validity{Valid: bytes.Equal(old, head)}
+	}
+	v.Valid = content.Valid
+	v.CurrentLineRef = content.CurrentLineRef
+	return v, nil
+}
+
+// resolveRef returns the commit a checker, scoped or not, treats as HEAD in
+// the evidence's repository. A caching checker answers from its cache.
+func resolveRef(ev *EvidenceNode, checker GitChecker) (string, error) {
+	if cache, ok := checker.(ValidityCache); ok {
+		return cache.CachedHead(ev)
+	}
+	resolver, ok := Capability[HeadResolver](checker)
+	if !ok {
+		return "", fmt.Errorf("checking against a ref is not supported by this checker")
+	}
+	return resolver.HeadCommit(ev.FilePath)
+}
+
+// containsCommit reports whether commit contains the commit ev cites.
+func containsCommit(ev *EvidenceNode, commit string, checker GitChecker) (bool, error) {
+	ancestry, ok := Capability[AncestryChecker](checker)
+	if !ok {
+		return true, nil
+	}
+	return ancestry.IsAncestor(ev.GitCommit, commit, ev.FilePath)
+}
+
+// isDirty reports whether the lines a valid citation occupies at HEAD have
+// uncommitted changes. Checkers that cannot see the working tree never
+// report dirty evidence.
+func isDirty(ev *EvidenceNode, v Validity, checker GitChecker) bool {
+	wt, ok := Capability[WorkingTreeChecker](checker)
+	if !ok {
+		return false
+	}
+	path, ref := ev.FilePath, ev.LineRef
+	if v.CurrentPath != "" {
+		path = v.CurrentPath
+	}
+	if v.CurrentLineRef != "" {
+		ref = v.CurrentLineRef
+	}
+	lines, err := ParseLineRef(ref)
+	if err != nil {
+		return false
+	}
+	changed, err := wt.UncommittedLines(path)
+	if err != nil {
+		return false
+	}
+	return rangesTouched(lines, changed)
+}
+
+// symbolValidity compares the source of a symbol-anchored citation at its
+// recorded commit with its source at HEAD.
+func symbolValidity(ev *EvidenceNode, old, head []byte, opts CheckOptions) Validity {
+	_, _, oldText, err := symbolSource(old, ev.Symbol)
+	if err != nil {
+		return Validity{Valid: false}
+	}
+	start, end, headText, err := symbolSource(head, ev.Symbol)
+	if err != nil {
+		return Validity{Valid: false}
+	}
+	v := Validity{Valid: oldText == headText}
+	if opts.Normalize {
+		v.Valid = sameTokens(ev.FilePath, oldText, headText)
+	}
+	if current := (LineRange{Start: start, End: end}).String(); current != ev.LineRef {
+		v.CurrentLineRef = current
+	}
+	return v
+}
+
+// Reanchor moves a still-valid evidence node to where its citation lives at
+// HEAD: the current path, the current lines and the HEAD commit, and adds
+// the move to its history. Returns an error if the evidence is not found or
+// no longer valid, or if the checker cannot resolve HEAD.
+func (g *Graph) Reanchor(id string, checker GitChecker, opts CheckOptions) (*EvidenceNode, error) {
+	v, err := g.EvidenceValidity(id, checker, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Valid {
+		return nil, fmt.Errorf("evidence %q is no longer valid", id)
+	}
+	ev := g.Evidence[id]
+	path := ev.FilePath
+	if v.CurrentPath != "" {
+		path = v.CurrentPath
+	}
+	head := v.RefCommit
+	if head == "" {
+		resolver, ok := Capability[HeadResolver](checker)
+		if !ok {
+			return nil, fmt.Errorf("re-anchoring is not supported by this checker")
+		}
+		if head, err = resolver.HeadCommit(path); err != nil {
+			return nil, err
+		}
+	}
+	event := TimelineEvent{
+		Kind:         EventReanchored,
+		At:           time.Now(),
+		Commit:       head,
+		PrevCommit:   ev.GitCommit,
+		PrevFilePath: ev.FilePath,
+		PrevLineRef:  ev.LineRef,
+	}
+	ev.moveTo(path)
+	if v.CurrentLineRef != "" {
+		ev.setLineRef(v.CurrentLineRef)
+	}
+	ev.GitCommit = head
+	event.FilePath, event.LineRef = ev.FilePath, ev.LineRef
+	ev.History = append(ev.History, event)
+	// Evidence re-anchored after it was recorded stale is valid again.
+	if ev.LastCheck != nil {
+		g.RecordCheck(id, EvidenceValid, head, event.At)
+	}
+	return ev, nil
+}
+
+// AddSymbolEvidence adds evidence anchored on a Go symbol in the file at
+// filePath, resolving the symbol's line range as of gitCommit. Returns an
+// error if the path is not absolute, the commit is empty, or the symbol
+// cannot be found at that commit.
+func (g *Graph) AddSymbolEvidence(filePath, symbol, gitCommit string, reader RevisionReader) (*EvidenceNode, error) {
+	if !filepath.IsAbs(filePath) || gitCommit == "" {
+		return nil, fmt.Errorf("file_path must be absolute and git_commit is required")
+	}
+	src, err := reader.ReadFileAt(gitCommit, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s at %s: %v", filePath, gitCommit, err)
+	}
+	start, end, _, err := symbolSource(src, symbol)
+	if err != nil {
+		return nil, err
+	}
+	ev := g.AddEvidence(filePath, LineRange{Start: start, End: end}.String(), gitCommit)
+	ev.Symbol = symbol
+	return ev, nil
+}
+
+// VerifyCitation checks that a citation can be resolved before it is
+// recorded: the commit exists in the file's repository and is reachable from
+// HEAD or the default branch, the file exists at that commit, and every cited
+// line is within the file. It returns the full hash gitCommit names, which is
+// what should be recorded, so a branch name or short hash cannot later come
+// to mean another commit. An empty lineRef skips the line check. Checks the
+// checker has no capability for are skipped, and gitCommit is returned as
+// given when it cannot be resolved.
+func VerifyCitation(checker GitChecker, filePath, lineRef, gitCommit string) (string, error) {
+	if !filepath.IsAbs(filePath) || gitCommit == "" {
+		return "", fmt.Errorf("file_path must be absolute and git_commit is required")
+	}
+	var ranges []LineRange
+	if lineRef != "" {
+		var err error
+		if ranges, err = ParseLineRef(lineRef); err != nil {
+			return "", err
+		}
+	}
+	if scoped, err := ScopeChecker(checker, gitCommit); err == nil {
+		if resolver, ok := Capability[HeadResolver](scoped); ok {
+			commit, err := resolver.HeadCommit(filePath)
+			if err != nil {
+				return "", fmt.Errorf("commit %q not found in the repository of %s", gitCommit, filePath)
+			}
+			gitCommit = commit
+		}
+	}
+	if err := checkReachable(checker, filePath, gitCommit); err != nil {
+		return "", err
+	}
+	reader, ok := Capability[RevisionReader](checker)
+	if !ok {
+		return gitCommit, nil
+	}
+	src, err := reader.ReadFileAt(gitCommit, filePath)
+	if err != nil {
+		return "", fmt.Errorf("file %s does not exist at commit %s", filePath, gitCommit)
+	}
+	total := bytes.Count(src, []byte("\n"))
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		total++
+	}
+	for _, r := range ranges {
+		if r.End > total {
+			return "", fmt.Errorf("line %d is past the end of %s at commit %s (%d lines)", r.End, filePath, gitCommit, total)
+		}
+	}
+	return gitCommit, nil
+}
+
+// checkReachable returns an error if commit is reachable from neither HEAD
+// nor the default branch of the repository containing filePath, so evidence
+// is not recorded at a commit only one checkout has. It needs an
+// AncestryChecker, and the default branch is tried with a BranchResolver.
+func checkReachable(checker GitChecker, filePath, commit string) error {
+	ancestry, ok := Capability[AncestryChecker](checker)
+	if !ok {
+		return nil
+	}
+	tips := []string{"HEAD"}
+	if resolver, ok := Capability[BranchResolver](checker); ok {
+		if branch, err := resolver.DefaultBranch(filePath); err == nil {
+			tips = append(tips, branch)
+		}
+	}
+	for _, tip := range tips {
+		if ok, err := ancestry.IsAncestor(commit, tip, filePath); err == nil && ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("commit %s is not reachable from %s in the repository of %s", commit, strings.Join(tips, " or "), filePath)
+}
@@ -0,0 +1,604 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// mockRevisionChecker is a GitChecker that can also read file contents.
+type mockRevisionChecker struct {
+	mockGitChecker
+	mockRevisionReader
+}
+
+func TestEvidenceValiditySymbolUnchanged(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "14-16", "abc123")
+	ev.Symbol = "Validate"
+
+	checker := &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:/home/user/auth.go": true}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{
+			"abc123:/home/user/auth.go": symbolTestSource,
+			"HEAD:/home/user/auth.go":   "package auth\n\nimport \"strings\"\n" + symbolTestSource[len("package auth\n"):],
+		}},
+	}
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid {
+		t.Error("expected symbol evidence to be valid when only other code changed")
+	}
+	if v.CurrentLineRef != "16-18" {
+		t.Errorf("expected current line ref %q, got %q", "16-18", v.CurrentLineRef)
+	}
+}
+
+func TestEvidenceValiditySymbolChanged(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "14-16", "abc123")
+	ev.Symbol = "Validate"
+
+	checker := &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:/home/user/auth.go": true}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{
+			"abc123:/home/user/auth.go": symbolTestSource,
+			"HEAD:/home/user/auth.go":   "package auth\n\nfunc Validate(t Token) bool {\n\treturn true\n}\n",
+		}},
+	}
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected symbol evidence to be invalid when its body changed")
+	}
+	if v.CurrentLineRef != "3-5" {
+		t.Errorf("expected current line ref %q, got %q", "3-5", v.CurrentLineRef)
+	}
+}
+
+func TestEvidenceValiditySymbolRemoved(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "14-16", "abc123")
+	ev.Symbol = "Validate"
+
+	checker := &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:/home/user/auth.go": true}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{
+			"abc123:/home/user/auth.go": symbolTestSource,
+			"HEAD:/home/user/auth.go":   "package auth\n",
+		}},
+	}
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected symbol evidence to be invalid when the symbol is gone")
+	}
+}
+
+func TestEvidenceValidityWithoutSymbolIgnoresContent(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "14-16", "abc123")
+
+	checker := &mockRevisionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:/home/user/auth.go": true}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{
+			"abc123:/home/user/auth.go": symbolTestSource,
+			"HEAD:/home/user/auth.go":   symbolTestSource,
+		}},
+	}
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected line evidence to be invalid when the file changed")
+	}
+}
+
+// mockRenameChecker is a mockRevisionChecker that also follows renames,
+// keyed by old path, and reports a fixed HEAD commit.
+type mockRenameChecker struct {
+	mockRevisionChecker
+	renames map[string]string
+	head    string
+}
+
+func (m *mockRenameChecker) CurrentPath(commit, filePath string) (string, error) {
+	if p, ok := m.renames[filePath]; ok {
+		return p, nil
+	}
+	return filePath, nil
+}
+
+func (m *mockRenameChecker) HeadCommit(filePath string) (string, error) {
+	return m.head, nil
+}
+
+func newRenameChecker(oldContent, newContent string) *mockRenameChecker {
+	return &mockRenameChecker{
+		mockRevisionChecker: mockRevisionChecker{
+			mockGitChecker: mockGitChecker{changed: map[string]bool{
+				"abc123:/home/user/old.go": true,
+				"abc123:/home/user/new.go": true,
+			}},
+			mockRevisionReader: mockRevisionReader{files: map[string]string{
+				"abc123:/home/user/old.go": oldContent,
+				"HEAD:/home/user/new.go":   newContent,
+			}},
+		},
+		renames: map[string]string{"/home/user/old.go": "/home/user/new.go"},
+		head:    "def456",
+	}
+}
+
+func TestEvidenceValidityFollowsRename(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "1", "abc123")
+	checker := newRenameChecker("package a\n", "package a\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid {
+		t.Error("expected evidence to be valid after a pure rename")
+	}
+	if v.CurrentPath != "/home/user/new.go" {
+		t.Errorf("expected current path %q, got %q", "/home/user/new.go", v.CurrentPath)
+	}
+}
+
+func TestEvidenceValidityRenamedAndChanged(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "1", "abc123")
+	checker := newRenameChecker("package a\n", "package b\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected evidence to be invalid when the renamed file changed")
+	}
+	if v.CurrentPath != "/home/user/new.go" {
+		t.Errorf("expected current path %q, got %q", "/home/user/new.go", v.CurrentPath)
+	}
+}
+
+func TestEvidenceValidityRenamedWithNormalize(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "3", "abc123")
+	checker := newRenameChecker("package a\n\nfunc A() {}\n", "package a\n\nfunc B() {}\n\nfunc A() {}\n")
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid {
+		t.Error("expected evidence to be valid when the cited lines survived the move")
+	}
+	if v.CurrentPath != "/home/user/new.go" || v.CurrentLineRef != "5" {
+		t.Errorf("expected new.go line 5, got %q line %q", v.CurrentPath, v.CurrentLineRef)
+	}
+}
+
+func TestReanchor(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "3", "abc123")
+	checker := newRenameChecker("package a\n\nfunc A() {}\n", "package a\n\nfunc B() {}\n\nfunc A() {}\n")
+
+	moved, err := g.Reanchor(ev.ID, checker, CheckOptions{Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if moved.FilePath != "/home/user/new.go" || moved.LineRef != "5" || moved.GitCommit != "def456" {
+		t.Errorf("expected new.go line 5 at def456, got %s line %s at %s", moved.FilePath, moved.LineRef, moved.GitCommit)
+	}
+}
+
+func TestReanchorRejectsStaleEvidence(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/old.go", "1", "abc123")
+	checker := newRenameChecker("package a\n", "package b\n")
+
+	if _, err := g.Reanchor(ev.ID, checker, CheckOptions{}); err == nil {
+		t.Error("expected error re-anchoring stale evidence")
+	}
+	if ev.FilePath != "/home/user/old.go" || ev.GitCommit != "abc123" {
+		t.Errorf("expected evidence to be unchanged, got %s at %s", ev.FilePath, ev.GitCommit)
+	}
+}
+
+func TestReanchorRequiresHeadResolver(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "1", "abc123")
+
+	if _, err := g.Reanchor(ev.ID, &mockGitChecker{changed: map[string]bool{}}, CheckOptions{}); err == nil {
+		t.Error("expected error when the checker cannot resolve HEAD")
+	}
+	if _, err := g.Reanchor("nonexistent", &mockGitChecker{changed: map[string]bool{}}, CheckOptions{}); err == nil {
+		t.Error("expected error for nonexistent evidence")
+	}
+}
+
+// mockDeletionChecker is a mockGitChecker that reports deleted files, keyed
+// by path.
+type mockDeletionChecker struct {
+	mockGitChecker
+	deleted map[string]string
+}
+
+func (m *mockDeletionChecker) DeletingCommit(commit, filePath string) (string, error) {
+	return m.deleted[filePath], nil
+}
+
+func TestEvidenceValidityStatus(t *testing.T) {
+	g := New()
+	valid := g.AddEvidence("/home/user/kept.go", "1", "abc123")
+	stale := g.AddEvidence("/home/user/changed.go", "1", "abc123")
+	deleted := g.AddEvidence("/home/user/gone.go", "1", "abc123")
+
+	checker := &mockDeletionChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{
+			"abc123:/home/user/changed.go": true,
+			"abc123:/home/user/gone.go":    true,
+		}},
+		deleted: map[string]string{"/home/user/gone.go": "def456"},
+	}
+
+	tests := []struct {
+		id        string
+		status    string
+		deletedIn string
+	}{
+		{valid.ID, EvidenceValid, ""},
+		{stale.ID, EvidenceStale, ""},
+		{deleted.ID, EvidenceDeleted, "def456"},
+	}
+	for _, tt := range tests {
+		v, err := g.EvidenceValidity(tt.id, checker, CheckOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v.Status != tt.status || v.DeletedIn != tt.deletedIn {
+			t.Errorf("expected status %q deleted in %q, got %q deleted in %q", tt.status, tt.deletedIn, v.Status, v.DeletedIn)
+		}
+		if v.Valid != (tt.status == EvidenceValid) {
+			t.Errorf("expected valid=%v for status %q", !v.Valid, tt.status)
+		}
+	}
+}
+
+// mockWorkingTreeChecker is a mockGitChecker that reports uncommitted line
+// changes, keyed by path.
+type mockWorkingTreeChecker struct {
+	mockGitChecker
+	uncommitted map[string][]LineRange
+}
+
+func (m *mockWorkingTreeChecker) UncommittedLines(filePath string) ([]LineRange, error) {
+	return m.uncommitted[filePath], nil
+}
+
+func TestEvidenceValidityDirty(t *testing.T) {
+	g := New()
+	edited := g.AddEvidence("/home/user/auth.go", "10-20", "abc123")
+	around := g.AddEvidence("/home/user/auth.go", "30-32", "abc123")
+	inserted := g.AddEvidence("/home/user/auth.go", "40-45", "abc123")
+	stale := g.AddEvidence("/home/user/changed.go", "1", "abc123")
+
+	checker := &mockWorkingTreeChecker{
+		mockGitChecker: mockGitChecker{changed: map[string]bool{"abc123:/home/user/changed.go": true}},
+		uncommitted: map[string][]LineRange{
+			"/home/user/auth.go":    {{15, 16}, {33, 32}, {43, 42}},
+			"/home/user/changed.go": {{1, 1}},
+		},
+	}
+
+	tests := []struct {
+		id     string
+		status string
+	}{
+		{edited.ID, EvidenceDirty},
+		{around.ID, EvidenceValid},
+		{inserted.ID, EvidenceDirty},
+		{stale.ID, EvidenceStale},
+	}
+	for _, tt := range tests {
+		v, err := g.EvidenceValidity(tt.id, checker, CheckOptions{WorkingTree: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if v.Status != tt.status {
+			t.Errorf("expected status %q, got %q", tt.status, v.Status)
+		}
+	}
+
+	v, _ := g.EvidenceValidity(edited.ID, checker, CheckOptions{})
+	if v.Status != EvidenceValid {
+		t.Errorf("expected working tree to be ignored by default, got %q", v.Status)
+	}
+	if !v.Valid {
+		t.Error("expected dirty evidence to remain valid")
+	}
+
+	if _, err := g.EvidenceValidity(edited.ID, checker, CheckOptions{WorkingTree: true, Ref: "main"}); err == nil {
+		t.Error("expected an error checking uncommitted edits against a ref")
+	}
+}
+
+// mockRefChecker reports changes per ref: a file has changed when it is
+// listed under the ref the checker is scoped to.
+type mockRefChecker struct {
+	ref     string
+	commits map[string]string   // ref -> resolved commit
+	changed map[string][]string // ref -> changed paths
+}
+
+func (m *mockRefChecker) HasFileChangedSince(commit, filePath string) (bool, error) {
+	for _, p := range m.changed[m.ref] {
+		if p == filePath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRefChecker) AtRef(ref string) GitChecker {
+	return &mockRefChecker{ref: ref, commits: m.commits, changed: m.changed}
+}
+
+func (m *mockRefChecker) HeadCommit(filePath string) (string, error) {
+	commit, ok := m.commits[m.ref]
+	if !ok {
+		return "", fmt.Errorf("unknown revision %q", m.ref)
+	}
+	return commit, nil
+}
+
+// countingHeadChecker counts how often HEAD itself is resolved.
+type countingHeadChecker struct {
+	*mockRefChecker
+	heads int
+}
+
+func (m *countingHeadChecker) HeadCommit(filePath string) (string, error) {
+	m.heads++
+	return m.mockRefChecker.HeadCommit(filePath)
+}
+
+func TestCheckEvidenceByRepo(t *testing.T) {
+	g := New()
+	a1 := g.AddEvidence("/home/user/a/x.go", "1", "abc123")
+	a2 := g.AddEvidence("/home/user/a/y.go", "1", "abc123")
+	b1 := g.AddEvidence("/home/user/b/x.go", "1", "abc123")
+
+	checker := &countingHeadChecker{mockRefChecker: &mockRefChecker{
+		commits: map[string]string{"": "head1", "head1": "head1"},
+		changed: map[string][]string{"head1": {"/home/user/a/x.go"}},
+	}}
+	validity, errs := g.CheckEvidenceByRepo([]string{a1.ID, a2.ID, b1.ID, a1.ID, "missing"}, checker, CheckOptions{})
+	if checker.heads != 2 {
+		t.Errorf("expected HEAD resolved once per checkout, got %d times", checker.heads)
+	}
+	if len(validity) != 3 || validity[a1.ID].Valid || !validity[a2.ID].Valid || !validity[b1.ID].Valid {
+		t.Errorf("expected a1 stale and a2 and b1 valid at head1, got %+v", validity)
+	}
+	if len(errs) != 1 || errs["missing"] == nil {
+		t.Errorf("expected an error for the missing node only, got %v", errs)
+	}
+}
+
+func TestEvidenceValidityAtRef(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+
+	checker := &mockRefChecker{
+		commits: map[string]string{"HEAD": "local", "origin/main": "def456"},
+		changed: map[string][]string{"origin/main": {"/home/user/auth.go"}},
+	}
+
+	v, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid || v.Ref != "" || v.RefCommit != "" {
+		t.Errorf("expected valid evidence at HEAD without a ref, got %+v", v)
+	}
+
+	v, err = g.EvidenceValidity(ev.ID, checker, CheckOptions{Ref: "origin/main"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid {
+		t.Error("expected evidence to be stale at origin/main")
+	}
+	if v.Ref != "origin/main" || v.RefCommit != "def456" {
+		t.Errorf("expected ref origin/main at def456, got %q at %q", v.Ref, v.RefCommit)
+	}
+
+	if _, err := g.EvidenceValidity(ev.ID, checker, CheckOptions{Ref: "nope"}); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+	if _, err := g.EvidenceValidity(ev.ID, &mockGitChecker{}, CheckOptions{Ref: "origin/main"}); err == nil {
+		t.Error("expected error for checker without ref support")
+	}
+}
+
+// mockCitationChecker resolves refs and reads file contents.
+type mockCitationChecker struct {
+	mockRefChecker
+	mockRevisionReader
+}
+
+func (m *mockCitationChecker) AtRef(ref string) GitChecker {
+	return &mockCitationChecker{mockRefChecker: mockRefChecker{ref: ref, commits: m.commits}, mockRevisionReader: m.mockRevisionReader}
+}
+
+func TestVerifyCitation(t *testing.T) {
+	checker := &mockCitationChecker{
+		mockRefChecker:     mockRefChecker{commits: map[string]string{"abc123": "abc123", "main": "abc123", "def456": "def456"}},
+		mockRevisionReader: mockRevisionReader{files: map[string]string{"abc123:/home/user/auth.go": "one\ntwo\nthree"}},
+	}
+
+	tests := []struct {
+		path, lineRef, commit string
+		valid                 bool
+	}{
+		{"/home/user/auth.go", "1-3", "abc123", true},
+		{"/home/user/auth.go", "", "abc123", true},
+		{"/home/user/auth.go", "1", "main", true},
+		{"auth.go", "1", "abc123", false},
+		{"/home/user/auth.go", "1", "", false},
+		{"/home/user/auth.go", "1", "nope", false},
+		{"/home/user/auth.go", "1", "def456", false},
+		{"/home/user/other.go", "1", "abc123", false},
+		{"/home/user/auth.go", "2,4", "abc123", false},
+	}
+	for _, tt := range tests {
+		commit, err := VerifyCitation(checker, tt.path, tt.lineRef, tt.commit)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s %s @%s: expected valid=%v, got error %v", tt.path, tt.lineRef, tt.commit, tt.valid, err)
+		}
+		if err == nil && commit != "abc123" {
+			t.Errorf("%s %s @%s: expected commit abc123, got %s", tt.path, tt.lineRef, tt.commit, commit)
+		}
+	}
+
+	if commit, err := VerifyCitation(&mockGitChecker{}, "/home/user/auth.go", "99", "nope"); err != nil || commit != "nope" {
+		t.Errorf("expected checks to be skipped for a plain checker, got %s (%v)", commit, err)
+	}
+}
+
+func TestVerifyCitationResolvesCommits(t *testing.T) {
+	r := newGitTestRepo(t)
+	r.write("a.go", "package a\n")
+	first := r.commit("add a")
+	r.git("checkout", "-q", "-b", "side")
+	r.write("a.go", "package a\n\nconst A = 1\n")
+	side := r.commit("add A")
+	r.git("checkout", "-q", "-")
+
+	for _, checker := range []GitChecker{&ExecGitChecker{}, NewNativeGitChecker()} {
+		for _, rev := range []string{"HEAD", first[:7], first} {
+			commit, err := VerifyCitation(checker, r.path("a.go"), "1", rev)
+			if err != nil || commit != first {
+				t.Errorf("%T: expected %s to resolve to %s, got %s (%v)", checker, rev, first, commit, err)
+			}
+		}
+		if _, err := VerifyCitation(checker, r.path("a.go"), "1", side); err == nil {
+			t.Errorf("%T: expected an error for a commit only on another branch", checker)
+		}
+		if _, err := VerifyCitation(checker, r.path("a.go"), "1", "--output=/tmp/x"); err == nil {
+			t.Errorf("%T: expected an error for a revision that is not a commit", checker)
+		}
+	}
+}
+
+func TestRecordCheck(t *testing.T) {
+	g := New()
+	ev := g.AddEvidence("/home/user/auth.go", "1", "abc123")
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	changed, err := g.RecordCheck(ev.ID, EvidenceValid, "head1", t0)
+	if err != nil || !changed {
+		t.Fatalf("expected first check to change status, got %v (%v)", changed, err)
+	}
+	changed, _ = g.RecordCheck(ev.ID, EvidenceValid, "head2", t0.Add(time.Hour))
+	if changed {
+		t.Error("expected unchanged status")
+	}
+	if ev.LastCheck.Commit != "head2" || !ev.LastCheck.CheckedAt.Equal(t0.Add(time.Hour)) || !ev.LastCheck.ChangedAt.Equal(t0) {
+		t.Errorf("expected check at head2 an hour later, unchanged since t0, got %+v", ev.LastCheck)
+	}
+	changed, _ = g.RecordCheck(ev.ID, EvidenceStale, "head3", t0.Add(2*time.Hour))
+	if !changed || ev.LastCheck.Status != EvidenceStale || !ev.LastCheck.ChangedAt.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("expected transition to stale, got %+v", ev.LastCheck)
+	}
+
+	if _, err := g.RecordCheck("nonexistent", EvidenceValid, "", t0); err == nil {
+		t.Error("expected error for nonexistent evidence")
+	}
+}
+
+// mockAncestryChecker is a mockRefChecker that knows which commits each
+// resolved commit contains.
+type mockAncestryChecker struct {
+	*mockRefChecker
+	contains map[string][]string
+}
+
+func (m *mockAncestryChecker) AtRef(ref string) GitChecker {
+	return &mockAncestryChecker{m.mockRefChecker.AtRef(ref).(*mockRefChecker), m.contains}
+}
+
+func (m *mockAncestryChecker) IsAncestor(ancestor, commit, filePath string) (bool, error) {
+	for _, c := range m.contains[commit] {
+		if c == ancestor {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestEvidenceValidityAsOf(t *testing.T) {
+	g := New()
+	old := g.AddEvidence("/home/user/auth.go", "10", "abc123")
+	recent := g.AddEvidence("/home/user/auth.go", "10", "fff999")
+
+	checker := &mockAncestryChecker{
+		mockRefChecker: &mockRefChecker{
+			commits: map[string]string{"v1.0": "def456"},
+			changed: map[string][]string{},
+		},
+		contains: map[string][]string{"def456": {"abc123", "def456"}},
+	}
+
+	v, err := g.EvidenceValidity(old.ID, checker, CheckOptions{Ref: "v1.0", AsOf: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !v.Valid || v.Status != EvidenceValid || v.RefCommit != "def456" {
+		t.Errorf("expected evidence valid as of def456, got %+v", v)
+	}
+
+	v, err = g.EvidenceValidity(recent.ID, checker, CheckOptions{Ref: "v1.0", AsOf: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid || v.Status != EvidenceNotInHistory || v.Ref != "v1.0" || v.RefCommit != "def456" {
+		t.Errorf("expected evidence not in history as of v1.0, got %+v", v)
+	}
+
+	// Without AsOf, a ref is only a point to compare against.
+	v, _ = g.EvidenceValidity(recent.ID, checker, CheckOptions{Ref: "v1.0"})
+	if v.Status != EvidenceValid {
+		t.Errorf("expected evidence valid against v1.0, got %+v", v)
+	}
+}
+
+func TestEvidenceValidityWithoutCheckout(t *testing.T) {
+	g := New()
+	g.Evidence["e1"] = &EvidenceNode{ID: "e1", Repo: "root1", Path: "auth.go", LineRef: "1", GitCommit: "abc123"}
+
+	v, err := g.EvidenceValidity("e1", &mockGitChecker{}, CheckOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Valid || v.Status != EvidenceNoCheckout {
+		t.Errorf("expected status %q, got %+v", EvidenceNoCheckout, v)
+	}
+	if _, err := g.Blame("e1", &ExecGitChecker{}); err == nil {
+		t.Error("expected blame to fail without a checkout")
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"trees/api"
 	"trees/graph"
+	"trees/store"
+	"trees/watch"
 )
 
 func main() {
@@ -18,12 +23,47 @@ func main() {
 		}
 		dataDir = filepath.Join(home, ".trees")
 	}
+	// The tree layout keeps one file per node in the data directory, which
+	// can be a .trees directory committed with a repository.
+	// Machine-specific files then go in its git-ignored local directory.
+	var s *store.Store
+	var err error
 	storePath := filepath.Join(dataDir, "data.json")
+	checkoutsPath := filepath.Join(dataDir, "repos.json")
+	switch os.Getenv("TREES_LAYOUT") {
+	case "", "file":
+		s, err = store.New(storePath)
+	case "tree":
+		storePath = dataDir
+		checkoutsPath = filepath.Join(dataDir, "local", "repos.json")
+		s, err = store.NewTree(dataDir)
+	default:
+		log.Fatalf("unknown TREES_LAYOUT %q (want file or tree)", os.Getenv("TREES_LAYOUT"))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	handler, err := api.NewHandler(storePath, &graph.ExecGitChecker{})
+	// The native checker needs no git binary, but cannot see the working
+	// tree or blame lines, so git is used wherever it is installed.
+	var checker graph.GitChecker = &graph.ExecGitChecker{}
+	if _, err := exec.LookPath("git"); err != nil {
+		log.Printf("git not found, reading repositories directly: %v", err)
+		checker = graph.NewNativeGitChecker()
+	}
+
+	handler := api.NewHandlerForStore(s, graph.NewCachingChecker(checker))
+	checkouts, err := loadCheckouts(checkoutsPath)
 	if err != nil {
 		log.Fatal(err)
 	}
+	handler.SetCheckouts(checkouts)
+
+	if watcher, err := watch.New(0); err != nil {
+		log.Printf("Not watching repositories: %v", err)
+	} else {
+		handler.Watch(watcher)
+	}
 
 	addr := os.Getenv("TREES_ADDR")
 	if addr == "" {
@@ -35,3 +75,20 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadCheckouts reads the JSON object at path mapping repository identities
+// to local checkout directories. A missing file maps nothing.
+func loadCheckouts(path string) (map[string]string, error) {
+	checkouts := map[string]string{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkouts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &checkouts); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return checkouts, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -8,3 +10,27 @@ func TestMain(t *testing.T) {
 	// Server startup is tested via the api package handler tests.
 	// This verifies the main package compiles correctly.
 }
+
+func TestLoadCheckouts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "repos.json")
+
+	checkouts, err := loadCheckouts(path)
+	if err != nil || len(checkouts) != 0 {
+		t.Fatalf("expected empty mapping for missing file, got %v, %v", checkouts, err)
+	}
+
+	os.WriteFile(path, []byte(`{"root1": "/home/bob/src/project"}`), 0644)
+	checkouts, err = loadCheckouts(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checkouts["root1"] != "/home/bob/src/project" {
+		t.Errorf("expected checkout %q, got %q", "/home/bob/src/project", checkouts["root1"])
+	}
+
+	os.WriteFile(path, []byte(`not json`), 0644)
+	if _, err := loadCheckouts(path); err == nil {
+		t.Error("expected error for malformed file")
+	}
+}
@@ -9,19 +9,37 @@ import (
 )
 
 type Store struct {
-	path string
-	g    *graph.Graph
-	mu   sync.RWMutex
+	layout layout
+	g      *graph.Graph
+	mu     sync.RWMutex
+}
+
+// layout reads and writes a graph in one on-disk format.
+type layout interface {
+	load(g *graph.Graph) error
+	save(g *graph.Graph) error
 }
 
+// New opens the store kept as a single JSON file at path.
 func New(path string) (*Store, error) {
+	return open(&fileLayout{path: path})
+}
+
+// NewTree opens the store kept as one file per node under dir, such as a
+// .trees directory inside a repository. See treeLayout.
+func NewTree(dir string) (*Store, error) {
+	return open(newTreeLayout(dir))
+}
+
+func open(l layout) (*Store, error) {
 	s := &Store{
-		path: path,
-		g:    graph.New(),
+		layout: l,
+		g:      graph.New(),
 	}
-	if err := s.load(); err != nil {
+	if err := l.load(s.g); err != nil {
 		return nil, err
 	}
+	s.g.FillDefaults()
 	return s, nil
 }
 
@@ -37,29 +55,68 @@ func (s *Store) WithGraph(fn func(g *graph.Graph)) {
 	fn(s.g)
 }
 
+// ReadGraph calls fn with the graph while holding the read lock, so writers
+// wait until it returns. fn must not modify the graph or keep references into
+// it.
+func (s *Store) ReadGraph(fn func(g *graph.Graph)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.g)
+}
+
+// Snapshot returns a copy of the graph taken under the read lock, for reading
+// at length, such as while checking evidence with git, without holding it.
+// Changes to the copy are not stored.
+func (s *Store) Snapshot() *graph.Graph {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.g.Copy()
+}
+
 func (s *Store) Save() error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.layout.save(s.g)
+}
 
-	dir := filepath.Dir(s.path)
+// fileLayout keeps the whole graph in one JSON file.
+type fileLayout struct {
+	path string
+}
+
+func (l *fileLayout) save(g *graph.Graph) error {
+	dir := filepath.Dir(l.path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(s.g, "", "  ")
+	// A file path that only joins the evidence's repository path to the
+	// repository's checkout is left out: FillDefaults restores it on load,
+	// and from whatever checkout is registered then.
+	saved := *g
+	saved.Evidence = make(map[string]*graph.EvidenceNode, len(g.Evidence))
+	for id, ev := range g.Evidence {
+		if p := g.CheckoutPath(ev); p != "" && ev.FilePath == p {
+			stripped := *ev
+			stripped.FilePath = ""
+			ev = &stripped
+		}
+		saved.Evidence[id] = ev
+	}
+	data, err := json.MarshalIndent(&saved, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.path, data, 0644)
+	return os.WriteFile(l.path, data, 0644)
 }
 
-func (s *Store) load() error {
-	data, err := os.ReadFile(s.path)
+func (l *fileLayout) load(g *graph.Graph) error {
+	data, err := os.ReadFile(l.path)
 	if os.IsNotExist(err) {
 		return nil
 	}
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, s.g)
+	return json.Unmarshal(data, g)
 }
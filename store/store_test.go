@@ -3,6 +3,7 @@ package store
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"trees/graph"
 )
@@ -75,6 +76,36 @@ func TestSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestSaveOmitsCheckoutPaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+
+	s, _ := New(path)
+	g := s.Graph()
+	g.RegisterRepo("root1", "/home/alice/project", "")
+	ev := g.AddEvidence("/home/alice/project/pkg/f.go", "1", "abc123")
+	ev.Repo, ev.Path = "root1", "pkg/f.go"
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "/home/alice/project/pkg/f.go") {
+		t.Errorf("expected no resolved file path in %s", data)
+	}
+	if ev.FilePath != "/home/alice/project/pkg/f.go" {
+		t.Errorf("expected the graph's file path kept, got %q", ev.FilePath)
+	}
+
+	s2, err := New(path)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := s2.Graph().GetEvidence(ev.ID).FilePath; got != "/home/alice/project/pkg/f.go" {
+		t.Errorf("expected file path restored from the checkout, got %q", got)
+	}
+}
+
 func TestLoadCreatesDirectoryIfNeeded(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "subdir", "nested", "data.json")
@@ -118,3 +149,20 @@ func TestConcurrentAccess(t *testing.T) {
 		t.Errorf("expected 10 claims, got %d", len(g.Claims))
 	}
 }
+
+func TestLoadFillsDefaultClaimStatus(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	data := `{"claims": {"c1": {"id": "c1", "content": "old claim"}}, "evidence": {}, "edges": []}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := s.Graph().GetClaim("c1").Status; got != graph.StatusDraft {
+		t.Errorf("expected status %q, got %q", graph.StatusDraft, got)
+	}
+}
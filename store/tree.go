@@ -0,0 +1,343 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"trees/graph"
+)
+
+// treeLayout keeps each claim, evidence node, edge and repository in its own
+// file under dir, so the graph can be committed next to the code it
+// describes and reviewed and merged node by node:
+//
+//	claims/<claim>.json
+//	evidence/<evidence>.json
+//	edges/<claim>/<evidence>.json
+//	repos/<repo>.json
+//
+// Files are indented JSON ending in a newline, and only files whose content
+// changed are rewritten. What differs between machines is kept apart in
+// local/, which the layout's .gitignore excludes: where each repository is
+// checked out and its default branch, the absolute path of evidence that has
+// a repository-relative one, and what the background checker records, each
+// evidence node's LastCheck and status history. Committed files therefore
+// only change when someone edits the graph.
+type treeLayout struct {
+	dir string
+	mu  sync.Mutex
+	// written holds the content last read or written for each file, by
+	// path relative to dir.
+	written map[string][]byte
+}
+
+const (
+	claimsDir   = "claims"
+	evidenceDir = "evidence"
+	edgesDir    = "edges"
+	reposDir    = "repos"
+	stateFile   = "local/state.json"
+)
+
+// localState is the machine-local part of the graph.
+type localState struct {
+	Evidence map[string]localEvidence `json:"evidence,omitempty"`
+	Repos    map[string]localRepo     `json:"repos,omitempty"`
+}
+
+// localEvidence is the machine-local state of one evidence node. FilePath is
+// only kept when it is not where the registered checkout puts the file.
+type localEvidence struct {
+	FilePath  string                `json:"file_path,omitempty"`
+	LastCheck *graph.CheckRecord    `json:"last_check,omitempty"`
+	History   []graph.TimelineEvent `json:"history,omitempty"`
+}
+
+// localRepo is where a repository is checked out on this machine.
+type localRepo struct {
+	Path          string `json:"path,omitempty"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+}
+
+func newTreeLayout(dir string) *treeLayout {
+	return &treeLayout{dir: dir, written: map[string][]byte{}}
+}
+
+// nodeFile returns the slash-separated path of a node's file relative to the
+// layout's directory. IDs are escaped so any ID makes a single file name.
+func nodeFile(dir string, ids ...string) string {
+	parts := []string{dir}
+	for _, id := range ids {
+		parts = append(parts, url.PathEscape(id))
+	}
+	return path.Join(parts...) + ".json"
+}
+
+// TreeFiles returns the graph in the tree layout, as file contents by
+// slash-separated path, without the machine-local state. It is how the graph
+// is shared through git.
+func TreeFiles(g *graph.Graph) (map[string][]byte, error) {
+	files, err := encodeTree(g)
+	if err != nil {
+		return nil, err
+	}
+	delete(files, stateFile)
+	return files, nil
+}
+
+// GraphFromTreeFiles builds a graph from files in the tree layout, by
+// slash-separated path. Files outside the layout are ignored. Returns an
+// error naming the first file that cannot be decoded or holds an invalid
+// node.
+func GraphFromTreeFiles(files map[string][]byte) (*graph.Graph, error) {
+	g := graph.New()
+	if err := decodeTree(g, files); err != nil {
+		return nil, err
+	}
+	g.FillDefaults()
+	return g, nil
+}
+
+// encodeTree renders every file of the layout, including the machine-local
+// state when there is any.
+func encodeTree(g *graph.Graph) (map[string][]byte, error) {
+	nodes := map[string]interface{}{}
+	state := localState{Evidence: map[string]localEvidence{}, Repos: map[string]localRepo{}}
+	for id, c := range g.Claims {
+		nodes[nodeFile(claimsDir, id)] = c
+	}
+	for id, ev := range g.Evidence {
+		shared := *ev
+		shared.LastCheck, shared.History = nil, nil
+		local := localEvidence{LastCheck: ev.LastCheck}
+		if ev.Repo != "" && ev.Path != "" {
+			shared.FilePath = ""
+			if ev.FilePath != g.CheckoutPath(ev) {
+				local.FilePath = ev.FilePath
+			}
+		}
+		for _, e := range ev.History {
+			if e.Kind == graph.EventStatus {
+				local.History = append(local.History, e)
+			} else {
+				shared.History = append(shared.History, e)
+			}
+		}
+		nodes[nodeFile(evidenceDir, id)] = &shared
+		if local.FilePath != "" || local.LastCheck != nil || len(local.History) > 0 {
+			state.Evidence[id] = local
+		}
+	}
+	for _, e := range g.Edges {
+		nodes[nodeFile(edgesDir, e.ClaimID, e.EvidenceID)] = e
+	}
+	for id, r := range g.Repos {
+		nodes[nodeFile(reposDir, id)] = &graph.Repo{ID: r.ID, CreatedAt: r.CreatedAt}
+		if r.Path != "" || r.DefaultBranch != "" {
+			state.Repos[id] = localRepo{Path: r.Path, DefaultBranch: r.DefaultBranch}
+		}
+	}
+	if len(state.Evidence) > 0 || len(state.Repos) > 0 {
+		nodes[stateFile] = state
+	}
+
+	files := make(map[string][]byte, len(nodes))
+	for rel, v := range nodes {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[rel] = append(data, '\n')
+	}
+	return files, nil
+}
+
+// decodeTree adds the nodes in files to g, in path order, and merges the
+// machine-local state back into the nodes it belongs to, interleaving
+// status changes with the shared history by time. Since the files may come
+// from anyone who can commit to the repository, each node must be stored
+// under its own ID and evidence must pass Validate.
+func decodeTree(g *graph.Graph, files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for rel := range files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	for _, rel := range paths {
+		dir, _, _ := strings.Cut(rel, "/")
+		if !strings.HasSuffix(rel, ".json") || rel == stateFile {
+			continue
+		}
+		var err error
+		var want string
+		switch dir {
+		case claimsDir:
+			var c graph.ClaimNode
+			if err = json.Unmarshal(files[rel], &c); err == nil {
+				g.Claims[c.ID] = &c
+				want = nodeFile(claimsDir, c.ID)
+			}
+		case evidenceDir:
+			var ev graph.EvidenceNode
+			if err = json.Unmarshal(files[rel], &ev); err == nil {
+				g.Evidence[ev.ID] = &ev
+				want = nodeFile(evidenceDir, ev.ID)
+				err = ev.Validate()
+			}
+		case edgesDir:
+			var e graph.Edge
+			if err = json.Unmarshal(files[rel], &e); err == nil {
+				g.Edges = append(g.Edges, e)
+				want = nodeFile(edgesDir, e.ClaimID, e.EvidenceID)
+			}
+		case reposDir:
+			var r graph.Repo
+			if err = json.Unmarshal(files[rel], &r); err == nil {
+				g.Repos[r.ID] = &r
+				want = nodeFile(reposDir, r.ID)
+			}
+		default:
+			continue
+		}
+		if err == nil && want != rel {
+			err = fmt.Errorf("holds the node for %s", want)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", rel, err)
+		}
+	}
+
+	data, ok := files[stateFile]
+	if !ok {
+		return nil
+	}
+	var state localState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %v", stateFile, err)
+	}
+	for id, local := range state.Repos {
+		if r, ok := g.Repos[id]; ok {
+			r.Path, r.DefaultBranch = local.Path, local.DefaultBranch
+		}
+	}
+	for id, local := range state.Evidence {
+		ev, ok := g.Evidence[id]
+		if !ok {
+			continue
+		}
+		if local.FilePath != "" {
+			ev.FilePath = local.FilePath
+		}
+		ev.LastCheck = local.LastCheck
+		ev.History = append(ev.History, local.History...)
+		sort.SliceStable(ev.History, func(i, j int) bool {
+			return ev.History[i].At.Before(ev.History[j].At)
+		})
+	}
+	return nil
+}
+
+func (l *treeLayout) save(g *graph.Graph) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := encodeTree(g)
+	if err != nil {
+		return err
+	}
+	if err := l.writeIgnore(); err != nil {
+		return err
+	}
+	for rel, data := range files {
+		if bytes.Equal(l.written[rel], data) {
+			continue
+		}
+		full := filepath.Join(l.dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, data, 0644); err != nil {
+			return err
+		}
+		l.written[rel] = data
+	}
+	for rel := range l.written {
+		if _, ok := files[rel]; ok {
+			continue
+		}
+		full := filepath.Join(l.dir, filepath.FromSlash(rel))
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(l.written, rel)
+		// Drop a claim's edge directory once its last edge is gone.
+		if strings.Count(rel, "/") == 2 && strings.HasPrefix(rel, edgesDir+"/") {
+			os.Remove(filepath.Dir(full))
+		}
+	}
+	return nil
+}
+
+// writeIgnore writes a .gitignore excluding the machine-local state, unless
+// one exists.
+func (l *treeLayout) writeIgnore() error {
+	full := filepath.Join(l.dir, ".gitignore")
+	if _, err := os.Stat(full); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(full, []byte("/local/\n"), 0644)
+}
+
+// load reads every file of the layout under the directory and remembers
+// its content. A missing directory holds an empty graph.
+func (l *treeLayout) load(g *graph.Graph) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files := map[string][]byte{}
+	for _, sub := range []string{claimsDir, evidenceDir, edgesDir, reposDir, path.Dir(stateFile)} {
+		root := filepath.Join(l.dir, sub)
+		err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(p, ".json") {
+				return nil
+			}
+			rel, err := filepath.Rel(l.dir, p)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := decodeTree(g, files); err != nil {
+		return err
+	}
+	for rel, data := range files {
+		l.written[rel] = data
+	}
+	return nil
+}
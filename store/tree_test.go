@@ -0,0 +1,247 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"trees/graph"
+)
+
+var (
+	commitA = strings.Repeat("a", 40)
+	commitB = strings.Repeat("b", 40)
+	commitC = strings.Repeat("c", 40)
+)
+
+func TestTreeSaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".trees")
+	s, err := NewTree(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g := s.Graph()
+	claim := g.AddClaim("test claim", "auth")
+	ev := g.AddEvidence("/home/user/file.go", "1-10", commitA)
+	g.LinkEvidence(claim.ID, ev.ID)
+	g.RegisterRepo("github.com/org/repo", "/home/user", "main")
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	g.RecordCheck(ev.ID, graph.EvidenceValid, commitB, at)
+	ev.History = append(ev.History, graph.TimelineEvent{Kind: graph.EventReanchored, At: at.Add(time.Hour), Commit: commitC})
+	g.RecordCheck(ev.ID, graph.EvidenceStale, commitC, at.Add(2*time.Hour))
+
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	for _, rel := range []string{
+		"claims/" + claim.ID + ".json",
+		"evidence/" + ev.ID + ".json",
+		"edges/" + claim.ID + "/" + ev.ID + ".json",
+		"repos/github.com%2Forg%2Frepo.json",
+		"local/state.json",
+		".gitignore",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); err != nil {
+			t.Errorf("expected %s to exist", rel)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "evidence", ev.ID+".json"))
+	if strings.Contains(string(data), "last_check") || strings.Contains(string(data), `"status"`) {
+		t.Errorf("expected checker state to be kept out of the evidence file, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), graph.EventReanchored) || !strings.HasSuffix(string(data), "}\n") {
+		t.Errorf("expected an indented file with the re-anchor, got:\n%s", data)
+	}
+
+	s2, err := NewTree(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	g2 := s2.Graph()
+	if c := g2.GetClaim(claim.ID); c == nil || c.Content != "test claim" || !c.HasTag("auth") {
+		t.Fatalf("expected the claim after load, got %+v", c)
+	}
+	if len(g2.Edges) != 1 || g2.Edges[0].Kind != graph.EdgeSupports {
+		t.Errorf("expected 1 supports edge, got %+v", g2.Edges)
+	}
+	if r := g2.Repos["github.com/org/repo"]; r == nil || r.DefaultBranch != "main" {
+		t.Errorf("expected the repository after load, got %+v", r)
+	}
+	loaded := g2.GetEvidence(ev.ID)
+	if loaded == nil || loaded.LastCheck == nil || loaded.LastCheck.Status != graph.EvidenceStale {
+		t.Fatalf("expected the last check after load, got %+v", loaded)
+	}
+	var kinds []string
+	for _, e := range loaded.History {
+		kinds = append(kinds, e.Kind)
+	}
+	if strings.Join(kinds, ",") != "status,reanchored,status" {
+		t.Errorf("expected history in time order, got %v", kinds)
+	}
+}
+
+func TestTreeSaveOnlyRewritesChanges(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewTree(dir)
+	g := s.Graph()
+	kept := g.AddClaim("kept")
+	ev := g.AddEvidence("/home/user/file.go", "1", commitA)
+	g.LinkEvidence(kept.ID, ev.ID)
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	keptPath := filepath.Join(dir, "claims", kept.ID+".json")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(keptPath, old, old)
+
+	g.AddClaim("added")
+	g.RemoveEvidence(ev.ID)
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	if info, _ := os.Stat(keptPath); !info.ModTime().Equal(old) {
+		t.Error("expected the unchanged claim file not to be rewritten")
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "claims"))
+	if len(entries) != 2 {
+		t.Errorf("expected 2 claim files, got %d", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evidence", ev.ID+".json")); !os.IsNotExist(err) {
+		t.Error("expected the removed evidence file to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "edges", kept.ID)); !os.IsNotExist(err) {
+		t.Error("expected the emptied edge directory to be deleted")
+	}
+}
+
+func TestTreeLoadReportsBadFile(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "claims"), 0755)
+	os.WriteFile(filepath.Join(dir, "claims", "c1.json"), []byte("{"), 0644)
+
+	_, err := NewTree(dir)
+	if err == nil || !strings.Contains(err.Error(), "c1.json") {
+		t.Errorf("expected an error naming the bad file, got %v", err)
+	}
+}
+
+func TestTreeKeepsRelinkedEdge(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewTree(dir)
+	s.WithGraph(func(g *graph.Graph) {
+		claim := g.AddClaim("test claim")
+		ev := g.AddEvidence("/home/user/file.go", "1-10", commitA)
+		g.LinkEvidence(claim.ID, ev.ID)
+		g.AddEdge(graph.Edge{ClaimID: claim.ID, EvidenceID: ev.ID, Kind: graph.EdgeRefutes})
+	})
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	s2, err := NewTree(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if edges := s2.Graph().Edges; len(edges) != 1 || edges[0].Kind != graph.EdgeRefutes {
+		t.Errorf("expected the one refuting edge, got %+v", edges)
+	}
+}
+
+func TestTreeFilesRoundTrip(t *testing.T) {
+	g := graph.New()
+	claim := g.AddClaim("test claim")
+	ev := g.AddEvidence("/home/user/file.go", "1-10", commitA)
+	g.LinkEvidence(claim.ID, ev.ID)
+	g.RecordCheck(ev.ID, graph.EvidenceValid, commitA, time.Now())
+
+	files, err := TreeFiles(g)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files without the local state, got %d", len(files))
+	}
+	if _, ok := files["edges/"+claim.ID+"/"+ev.ID+".json"]; !ok {
+		t.Error("expected slash-separated paths")
+	}
+
+	files["README"] = []byte("not a node")
+	g2, err := GraphFromTreeFiles(files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g2.Claims) != 1 || len(g2.Evidence) != 1 || len(g2.Edges) != 1 {
+		t.Errorf("expected 1 claim, evidence node and edge, got %d, %d and %d", len(g2.Claims), len(g2.Evidence), len(g2.Edges))
+	}
+	if g2.GetEvidence(ev.ID).LastCheck != nil {
+		t.Error("expected no local state in shared files")
+	}
+}
+
+func TestTreeKeepsCheckoutsLocal(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewTree(dir)
+	g := s.Graph()
+	g.RegisterRepo("root1", "/home/alice/project", "origin/main")
+	ev := g.AddEvidence("/home/alice/project/pkg/a.go", "1-2", commitA)
+	ev.Repo, ev.Path = "root1", "pkg/a.go"
+	// Evidence in another worktree of the same repository keeps its path.
+	other := g.AddEvidence("/home/alice/worktree/pkg/a.go", "3", commitA)
+	other.Repo, other.Path = "root1", "pkg/a.go"
+	if err := s.Save(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+
+	for _, rel := range []string{"evidence/" + ev.ID + ".json", "evidence/" + other.ID + ".json", "repos/root1.json"} {
+		data, _ := os.ReadFile(filepath.Join(dir, rel))
+		if strings.Contains(string(data), "/home/alice") || strings.Contains(string(data), "origin/main") {
+			t.Errorf("expected no machine-local paths in %s, got:\n%s", rel, data)
+		}
+	}
+
+	s2, err := NewTree(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	g2 := s2.Graph()
+	if r := g2.Repos["root1"]; r == nil || r.Path != "/home/alice/project" || r.DefaultBranch != "origin/main" {
+		t.Errorf("expected the checkout from the local state, got %+v", r)
+	}
+	if got := g2.GetEvidence(ev.ID).FilePath; got != ev.FilePath {
+		t.Errorf("expected %s resolved from the checkout, got %s", ev.FilePath, got)
+	}
+	if got := g2.GetEvidence(other.ID).FilePath; got != other.FilePath {
+		t.Errorf("expected %s from the local state, got %s", other.FilePath, got)
+	}
+
+	// A fresh clone has no local state, so the evidence waits for a checkout.
+	os.RemoveAll(filepath.Join(dir, "local"))
+	s3, err := NewTree(dir)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := s3.Graph().GetEvidence(ev.ID).FilePath; got != "" {
+		t.Errorf("expected no file path without a checkout, got %s", got)
+	}
+}
+
+func TestTreeLoadRejectsInvalidNodes(t *testing.T) {
+	cases := map[string]string{
+		"evidence/e1.json": `{"id": "e1", "file_path": "/src/a.go", "line_ref": "1", "git_commit": "--output=/tmp/x"}`,
+		"evidence/e2.json": `{"id": "e2", "repo": "r", "path": "../../etc/passwd", "line_ref": "1", "git_commit": "` + commitA + `"}`,
+		"evidence/e3.json": `{"id": "other", "file_path": "/src/a.go", "line_ref": "1", "git_commit": "` + commitA + `"}`,
+		"claims/c1.json":   `{"id": "c2", "content": "moved"}`,
+	}
+	for rel, content := range cases {
+		dir := t.TempDir()
+		full := filepath.Join(dir, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+
+		if _, err := NewTree(dir); err == nil || !strings.Contains(err.Error(), rel) {
+			t.Errorf("%s: expected an error naming the file, got %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// Events that show a file was replaced, written or removed. Git updates refs
+// by renaming a lock file over them, which arrives as IN_MOVED_TO.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_CLOSE_WRITE | syscall.IN_DELETE
+
+// inotifyBackend watches git directories with inotify. Since inotify is not
+// recursive, every directory under refs is watched individually, including
+// ones created later.
+type inotifyBackend struct {
+	// fd is kept apart from file, since calling file.Fd would make reads
+	// blocking and keep close from interrupting them.
+	fd     int
+	file   *os.File
+	notify func(gitDir string)
+
+	mu      sync.Mutex
+	watches map[int32]*watchedDir
+	done    chan struct{}
+}
+
+// watchedDir is a directory under inotify watch and the repositories whose
+// refs live in it.
+type watchedDir struct {
+	path string
+	// refs is set for directories under refs/, where any file is a ref.
+	// Otherwise only HEAD and packed-refs matter.
+	refs   bool
+	owners []string
+}
+
+func newBackend(notify func(gitDir string)) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	b := &inotifyBackend{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		notify:  notify,
+		watches: make(map[int32]*watchedDir),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *inotifyBackend) add(r gitDirs) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.watch(r.gitDir, false, r.gitDir); err != nil {
+		return err
+	}
+	if r.commonDir != r.gitDir {
+		if err := b.watch(r.commonDir, false, r.gitDir); err != nil {
+			return err
+		}
+	}
+	return b.watchTree(filepath.Join(r.commonDir, "refs"), r.gitDir)
+}
+
+// watchTree watches dir and every directory below it for owner.
+func (b *inotifyBackend) watchTree(dir, owner string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// A directory removed while walking is simply not watched.
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return b.watch(path, true, owner)
+	})
+}
+
+// watch adds an inotify watch on dir for owner. Watching a directory again
+// returns the same descriptor, so owners accumulate. b.mu must be held.
+func (b *inotifyBackend) watch(dir string, refs bool, owner string) error {
+	wd, err := syscall.InotifyAddWatch(b.fd, dir, inotifyMask|syscall.IN_ONLYDIR)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	w, ok := b.watches[int32(wd)]
+	if !ok {
+		w = &watchedDir{path: dir, refs: refs}
+		b.watches[int32(wd)] = w
+	}
+	for _, o := range w.owners {
+		if o == owner {
+			return nil
+		}
+	}
+	w.owners = append(w.owners, owner)
+	return nil
+}
+
+func (b *inotifyBackend) run() {
+	defer close(b.done)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			if nameEnd > n {
+				break
+			}
+			name := string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+			b.handle(event.Wd, event.Mask, name)
+			offset = nameEnd
+		}
+	}
+}
+
+// handle reacts to one event on the watched directory wd.
+func (b *inotifyBackend) handle(wd int32, mask uint32, name string) {
+	b.mu.Lock()
+	w, ok := b.watches[wd]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(b.watches, wd)
+		b.mu.Unlock()
+		return
+	}
+	if strings.HasSuffix(name, ".lock") || (!w.refs && !isRefFile(name)) {
+		b.mu.Unlock()
+		return
+	}
+	if w.refs && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		for _, owner := range w.owners {
+			b.watchTree(filepath.Join(w.path, name), owner)
+		}
+	}
+	owners := append([]string(nil), w.owners...)
+	b.mu.Unlock()
+
+	for _, owner := range owners {
+		b.notify(owner)
+	}
+}
+
+func (b *inotifyBackend) close() error {
+	err := b.file.Close()
+	<-b.done
+	return err
+}
@@ -0,0 +1,98 @@
+//go:build !linux
+
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often refs are compared where inotify is unavailable.
+const pollInterval = time.Second
+
+// pollBackend watches git directories by periodically comparing the size
+// and modification time of every ref file.
+type pollBackend struct {
+	notify func(gitDir string)
+
+	mu    sync.Mutex
+	repos map[string]gitDirs
+	state map[string]string // git dir -> fingerprint of its refs
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newBackend(notify func(gitDir string)) (backend, error) {
+	b := &pollBackend{
+		notify: notify,
+		repos:  make(map[string]gitDirs),
+		state:  make(map[string]string),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+func (b *pollBackend) add(r gitDirs) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.repos[r.gitDir] = r
+	b.state[r.gitDir] = fingerprint(r)
+	return nil
+}
+
+func (b *pollBackend) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		var changed []string
+		b.mu.Lock()
+		for gitDir, r := range b.repos {
+			if fp := fingerprint(r); fp != b.state[gitDir] {
+				b.state[gitDir] = fp
+				changed = append(changed, gitDir)
+			}
+		}
+		b.mu.Unlock()
+		for _, gitDir := range changed {
+			b.notify(gitDir)
+		}
+	}
+}
+
+// fingerprint summarizes the ref files of a repository so that any ref
+// update changes it.
+func fingerprint(r gitDirs) string {
+	var b strings.Builder
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	stat(filepath.Join(r.gitDir, "HEAD"))
+	stat(filepath.Join(r.commonDir, "packed-refs"))
+	filepath.WalkDir(filepath.Join(r.commonDir, "refs"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && !strings.HasSuffix(path, ".lock") {
+			stat(path)
+		}
+		return nil
+	})
+	return b.String()
+}
+
+func (b *pollBackend) close() error {
+	close(b.stop)
+	<-b.done
+	return nil
+}
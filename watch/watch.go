@@ -0,0 +1,186 @@
+// Package watch notices when the refs of git repositories move: a new
+// commit, a checkout, a fetch, a rebase or a reset.
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDelay is how long a repository's refs must stay still before a
+// change is reported.
+const DefaultDelay = 200 * time.Millisecond
+
+// Watcher reports ref changes in the repositories added to it. It watches
+// each repository's HEAD, its refs directory and its packed-refs file.
+type Watcher struct {
+	// Changes receives the checkout directory of a repository whose refs
+	// moved. A burst of updates, such as a fetch or a rebase, is reported
+	// once after it settles.
+	Changes <-chan string
+
+	changes chan string
+	delay   time.Duration
+	backend backend
+
+	mu      sync.Mutex
+	repos   map[string]string // git dir -> checkout directory
+	timers  map[string]*time.Timer
+	closed  bool
+	done    chan struct{}
+	sending sync.WaitGroup
+}
+
+// backend is the mechanism that notices changes to git directories: inotify
+// on Linux, polling elsewhere.
+type backend interface {
+	// add starts watching the files of a repository.
+	add(r gitDirs) error
+	close() error
+}
+
+// gitDirs locates the ref storage of a checkout. In a linked worktree, HEAD
+// lives in its own git directory while refs are shared in the common one.
+type gitDirs struct {
+	gitDir    string
+	commonDir string
+}
+
+// New returns a watcher that reports changes once refs have been still for
+// delay, or DefaultDelay if delay is zero.
+func New(delay time.Duration) (*Watcher, error) {
+	if delay == 0 {
+		delay = DefaultDelay
+	}
+	changes := make(chan string, 16)
+	w := &Watcher{
+		Changes: changes,
+		changes: changes,
+		delay:   delay,
+		repos:   make(map[string]string),
+		timers:  make(map[string]*time.Timer),
+		done:    make(chan struct{}),
+	}
+	b, err := newBackend(w.notify)
+	if err != nil {
+		return nil, err
+	}
+	w.backend = b
+	return w, nil
+}
+
+// Add starts watching the repository checked out at dir. Adding a
+// repository twice has no effect.
+func (w *Watcher) Add(dir string) error {
+	dirs, err := findGitDirs(dir)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("watcher is closed")
+	}
+	if _, ok := w.repos[dirs.gitDir]; ok {
+		return nil
+	}
+	if err := w.backend.add(dirs); err != nil {
+		return err
+	}
+	w.repos[dirs.gitDir] = dir
+	return nil
+}
+
+// Close stops watching and closes Changes.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	for _, t := range w.timers {
+		t.Stop()
+	}
+	w.mu.Unlock()
+
+	err := w.backend.close()
+	w.sending.Wait()
+	close(w.changes)
+	return err
+}
+
+// notify is called by the backend when something under gitDir changed. The
+// change is reported after the repository has been still for w.delay.
+func (w *Watcher) notify(gitDir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dir, ok := w.repos[gitDir]
+	if !ok || w.closed {
+		return
+	}
+	if t, ok := w.timers[gitDir]; ok {
+		t.Reset(w.delay)
+		return
+	}
+	w.timers[gitDir] = time.AfterFunc(w.delay, func() {
+		w.mu.Lock()
+		delete(w.timers, gitDir)
+		if w.closed {
+			w.mu.Unlock()
+			return
+		}
+		w.sending.Add(1)
+		w.mu.Unlock()
+		defer w.sending.Done()
+		select {
+		case w.changes <- dir:
+		case <-w.done:
+		}
+	})
+}
+
+// findGitDirs returns the git directories of the checkout at dir, whose .git
+// may be a directory or, in worktrees and submodules, a file pointing at one.
+func findGitDirs(dir string) (gitDirs, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return gitDirs{}, fmt.Errorf("%s is not a git checkout", dir)
+	}
+	gitDir := dotGit
+	if !info.IsDir() {
+		data, err := os.ReadFile(dotGit)
+		if err != nil {
+			return gitDirs{}, err
+		}
+		line := strings.TrimSpace(string(data))
+		if !strings.HasPrefix(line, "gitdir: ") {
+			return gitDirs{}, fmt.Errorf("%s: malformed .git file", dir)
+		}
+		gitDir = strings.TrimPrefix(line, "gitdir: ")
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(dir, gitDir)
+		}
+	}
+	dirs := gitDirs{gitDir: filepath.Clean(gitDir), commonDir: filepath.Clean(gitDir)}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		dirs.commonDir = filepath.Clean(common)
+	}
+	return dirs, nil
+}
+
+// isRefFile reports whether name, a file directly inside a git directory, is
+// one whose changes move refs.
+func isRefFile(name string) bool {
+	return name == "HEAD" || name == "packed-refs"
+}
@@ -0,0 +1,148 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCheckout lays out the ref files of a git checkout in a temporary
+// directory.
+func newCheckout(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git", "refs", "heads"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git", "refs", "tags"), 0755)
+	writeRef(t, dir, "HEAD", "ref: refs/heads/main\n")
+	writeRef(t, dir, "refs/heads/main", "1111111111111111111111111111111111111111\n")
+	return dir
+}
+
+// writeRef updates a file under .git the way git does, by renaming a lock
+// file over it.
+func writeRef(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, ".git", filepath.FromSlash(name))
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path+".lock", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".lock", path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newWatcher(t *testing.T, dir string) *Watcher {
+	t.Helper()
+	w, err := New(20 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return w
+}
+
+func expectChange(t *testing.T, w *Watcher, dir string) {
+	t.Helper()
+	select {
+	case got := <-w.Changes:
+		if got != dir {
+			t.Errorf("expected change in %s, got %s", dir, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a change to be reported")
+	}
+}
+
+func expectNoChange(t *testing.T, w *Watcher) {
+	t.Helper()
+	select {
+	case got := <-w.Changes:
+		t.Errorf("expected no change, got one in %s", got)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatcherReportsRefUpdates(t *testing.T) {
+	dir := newCheckout(t)
+	w := newWatcher(t, dir)
+
+	writeRef(t, dir, "refs/heads/main", "2222222222222222222222222222222222222222\n")
+	expectChange(t, w, dir)
+
+	writeRef(t, dir, "HEAD", "ref: refs/heads/other\n")
+	expectChange(t, w, dir)
+
+	writeRef(t, dir, "packed-refs", "3333333333333333333333333333333333333333 refs/tags/v1\n")
+	expectChange(t, w, dir)
+}
+
+func TestWatcherFollowsNewRefDirectories(t *testing.T) {
+	dir := newCheckout(t)
+	w := newWatcher(t, dir)
+
+	writeRef(t, dir, "refs/heads/feature/x", "2222222222222222222222222222222222222222\n")
+	expectChange(t, w, dir)
+
+	writeRef(t, dir, "refs/heads/feature/x", "3333333333333333333333333333333333333333\n")
+	expectChange(t, w, dir)
+}
+
+func TestWatcherCoalescesBursts(t *testing.T) {
+	dir := newCheckout(t)
+	w := newWatcher(t, dir)
+
+	writeRef(t, dir, "refs/heads/main", "2222222222222222222222222222222222222222\n")
+	writeRef(t, dir, "refs/tags/v1", "2222222222222222222222222222222222222222\n")
+	writeRef(t, dir, "HEAD", "2222222222222222222222222222222222222222\n")
+	expectChange(t, w, dir)
+	expectNoChange(t, w)
+}
+
+func TestWatcherIgnoresOtherFiles(t *testing.T) {
+	dir := newCheckout(t)
+	w := newWatcher(t, dir)
+
+	writeRef(t, dir, "index", "not a ref")
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	expectNoChange(t, w)
+}
+
+func TestWatcherWorktree(t *testing.T) {
+	main := newCheckout(t)
+	worktree := t.TempDir()
+	gitDir := filepath.Join(main, ".git", "worktrees", "wt")
+	os.MkdirAll(gitDir, 0755)
+	os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/wt\n"), 0644)
+	os.WriteFile(filepath.Join(gitDir, "commondir"), []byte("../..\n"), 0644)
+	os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644)
+
+	w := newWatcher(t, worktree)
+
+	writeRef(t, main, "refs/heads/wt", "2222222222222222222222222222222222222222\n")
+	expectChange(t, w, worktree)
+
+	writeRef(t, main, "worktrees/wt/HEAD", "3333333333333333333333333333333333333333\n")
+	expectChange(t, w, worktree)
+}
+
+func TestWatcherErrors(t *testing.T) {
+	w, err := New(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Add(t.TempDir()); err == nil {
+		t.Error("expected error for a directory that is not a checkout")
+	}
+	w.Close()
+	if _, ok := <-w.Changes; ok {
+		t.Error("expected Changes to be closed")
+	}
+	if err := w.Add(newCheckout(t)); err == nil {
+		t.Error("expected error adding to a closed watcher")
+	}
+}